@@ -9,6 +9,7 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position
 }
 
 type Expression interface {
@@ -33,6 +34,14 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -51,6 +60,7 @@ type IndexExpression struct {
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -63,6 +73,73 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// left[low:high], either bound may be left out
+type SliceExpression struct {
+	Token token.Token
+	Left  Expression
+	Low   Expression
+	High  Expression
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+type MemberExpression struct {
+	Token    token.Token
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) Pos() token.Position  { return me.Token.Pos }
+func (me *MemberExpression) String() string {
+	return me.Object.String() + "." + me.Property.String()
+}
+
+type HashLiteral struct {
+	Token token.Token
+	Pairs map[Expression]Expression
+	// the keys of Pairs in source order, the map has none
+	Keys []Expression
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+": "+hl.Pairs[key].String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
 type ArrayLiteral struct {
 	Token    token.Token
 	Elements []Expression
@@ -70,6 +147,7 @@ type ArrayLiteral struct {
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -94,6 +172,10 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
+
 func (i *Identifier) expressionNode() {}
 
 func (i *Identifier) String() string {
@@ -110,8 +192,17 @@ func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+func (ls *LetStatement) Pos() token.Position {
+	return ls.Token.Pos
+}
+
 func (ls *LetStatement) statementNode() {}
 
+// declared with const, the binding can not be assigned or redeclared
+func (ls *LetStatement) Constant() bool {
+	return ls.Token.Type == token.CONST
+}
+
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -128,6 +219,124 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// Target is an *Identifier, *IndexExpression or *MemberExpression
+type AssignExpression struct {
+	Token    token.Token
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+
+func (ae *AssignExpression) Pos() token.Position {
+	return ae.Token.Pos
+}
+
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+
+	return out.String()
+}
+
+// struct Name { field, ... } declares a record type
+type StructStatement struct {
+	Token  token.Token
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (ss *StructStatement) TokenLiteral() string {
+	return ss.Token.Literal
+}
+
+func (ss *StructStatement) Pos() token.Position {
+	return ss.Token.Pos
+}
+
+func (ss *StructStatement) statementNode() {}
+
+func (ss *StructStatement) String() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for _, f := range ss.Fields {
+		fields = append(fields, f.String())
+	}
+
+	out.WriteString("struct " + ss.Name.Value + " { ")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// fn Name(params) { body }, the name is bound before any other statement
+// of the enclosing program or block runs
+type FunctionStatement struct {
+	Token    token.Token
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+func (fs *FunctionStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+func (fs *FunctionStatement) Pos() token.Position {
+	return fs.Token.Pos
+}
+
+func (fs *FunctionStatement) statementNode() {}
+
+func (fs *FunctionStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(fs.TokenLiteral() + " ")
+	out.WriteString(fs.Name.String())
+	out.WriteString("(")
+	out.WriteString(FormatParameters(fs.Function.Parameters, fs.Function.Defaults, fs.Function.Rest))
+	out.WriteString(")")
+	out.WriteString(fs.Function.Body.String())
+
+	return out.String()
+}
+
+type ImportStatement struct {
+	Token token.Token
+	Path  *String
+	Alias *Identifier
+}
+
+func (is *ImportStatement) TokenLiteral() string {
+	return is.Token.Literal
+}
+
+func (is *ImportStatement) Pos() token.Position {
+	return is.Token.Pos
+}
+
+func (is *ImportStatement) statementNode() {}
+
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(is.TokenLiteral() + " ")
+	out.WriteString("\"" + is.Path.Value + "\"")
+	out.WriteString(" as " + is.Alias.Value)
+	out.WriteString(";")
+
+	return out.String()
+}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -137,6 +346,10 @@ func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
 
+func (es *ExpressionStatement) Pos() token.Position {
+	return es.Token.Pos
+}
+
 func (es *ExpressionStatement) statementNode() {}
 
 func (es *ExpressionStatement) String() string {
@@ -158,10 +371,33 @@ func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
 
+func (il *IntegerLiteral) Pos() token.Position {
+	return il.Token.Pos
+}
+
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -174,6 +410,10 @@ func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
 
+func (pe *PrefixExpression) Pos() token.Position {
+	return pe.Token.Pos
+}
+
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -198,6 +438,10 @@ func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+func (ie *InfixExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
+
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -221,12 +465,17 @@ func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
 
+func (b *Boolean) Pos() token.Position {
+	return b.Token.Pos
+}
+
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
 
 type ForExpression struct {
 	Token       token.Token
+	Label       *Identifier
 	Declaration Statement
 	Condition   Expression
 	Increment   Statement
@@ -239,9 +488,16 @@ func (fe *ForExpression) TokenLiteral() string {
 	return fe.Token.Literal
 }
 
+func (fe *ForExpression) Pos() token.Position {
+	return fe.Token.Pos
+}
+
 func (fe *ForExpression) String() string {
 	var out bytes.Buffer
 
+	if fe.Label != nil {
+		out.WriteString(fe.Label.String() + ": ")
+	}
 	out.WriteString("for")
 	out.WriteString(fe.Declaration.String())
 	out.WriteString(" ")
@@ -256,6 +512,7 @@ func (fe *ForExpression) String() string {
 
 type WhileExpression struct {
 	Token       token.Token
+	Label       *Identifier
 	Condition   Expression
 	Consequence *BlockStatement
 }
@@ -266,9 +523,16 @@ func (we *WhileExpression) TokenLiteral() string {
 	return we.Token.Literal
 }
 
+func (we *WhileExpression) Pos() token.Position {
+	return we.Token.Pos
+}
+
 func (we *WhileExpression) String() string {
 	var out bytes.Buffer
 
+	if we.Label != nil {
+		out.WriteString(we.Label.String() + ": ")
+	}
 	out.WriteString("while")
 	out.WriteString(we.Condition.String())
 	out.WriteString(" ")
@@ -291,6 +555,10 @@ func (ife *IfExpression) TokenLiteral() string {
 	return ife.Token.Literal
 }
 
+func (ife *IfExpression) Pos() token.Position {
+	return ife.Token.Pos
+}
+
 func (ife *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -312,6 +580,87 @@ func (ife *IfExpression) String() string {
 	return out.String()
 }
 
+// try { Block } catch (Param) { Catch } finally { Finally }, at least one
+// of Catch and Finally is set
+type TryExpression struct {
+	Token   token.Token
+	Block   *BlockStatement
+	Param   *Identifier
+	Catch   *BlockStatement
+	Finally *BlockStatement
+}
+
+func (te *TryExpression) expressionNode() {}
+
+func (te *TryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+
+func (te *TryExpression) Pos() token.Position {
+	return te.Token.Pos
+}
+
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+
+	if te.Catch != nil {
+		out.WriteString(" catch(" + te.Param.String() + ") ")
+		out.WriteString(te.Catch.String())
+	}
+
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+
+	return out.String()
+}
+
+// match Value { Pattern => Body, ... }, the first arm whose pattern matches
+// is evaluated
+type MatchExpression struct {
+	Token token.Token
+	Value Expression
+	Arms  []*MatchArm
+}
+
+// Pattern is a literal, an identifier binding the value (_ binds nothing) or
+// an array literal of patterns matching arrays of the same length
+type MatchArm struct {
+	Pattern Expression
+	Body    *BlockStatement
+}
+
+func (me *MatchExpression) expressionNode() {}
+
+func (me *MatchExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+func (me *MatchExpression) Pos() token.Position {
+	return me.Token.Pos
+}
+
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.Pattern.String()+" => "+arm.Body.String())
+	}
+
+	out.WriteString("match ")
+	out.WriteString(me.Value.String())
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
@@ -323,6 +672,10 @@ func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
 
+func (bs *BlockStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -344,6 +697,10 @@ func (s *String) TokenLiteral() string {
 	return s.Token.Literal
 }
 
+func (s *String) Pos() token.Position {
+	return s.Token.Pos
+}
+
 func (s *String) String() string {
 	return s.Token.Literal
 }
@@ -359,6 +716,10 @@ func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
 
+func (rs *ReturnStatement) Pos() token.Position {
+	return rs.Token.Pos
+}
+
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -373,10 +734,79 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+type ThrowStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {}
+
+func (ts *ThrowStatement) TokenLiteral() string {
+	return ts.Token.Literal
+}
+
+func (ts *ThrowStatement) Pos() token.Position {
+	return ts.Token.Pos
+}
+
+func (ts *ThrowStatement) String() string {
+	return ts.TokenLiteral() + " " + ts.Value.String() + ";"
+}
+
+type BreakStatement struct {
+	Token token.Token
+	Label *Identifier
+}
+
+func (bs *BreakStatement) statementNode() {}
+
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+
+func (bs *BreakStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+
+func (bs *BreakStatement) String() string {
+	if bs.Label != nil {
+		return bs.TokenLiteral() + " " + bs.Label.String() + ";"
+	}
+
+	return bs.TokenLiteral() + ";"
+}
+
+type ContinueStatement struct {
+	Token token.Token
+	Label *Identifier
+}
+
+func (cs *ContinueStatement) statementNode() {}
+
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+
+func (cs *ContinueStatement) Pos() token.Position {
+	return cs.Token.Pos
+}
+
+func (cs *ContinueStatement) String() string {
+	if cs.Label != nil {
+		return cs.TokenLiteral() + " " + cs.Label.String() + ";"
+	}
+
+	return cs.TokenLiteral() + ";"
+}
+
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
-	Body       *BlockStatement
+	// default values of the last len(Defaults) parameters
+	Defaults []Expression
+	// collects the arguments past Parameters into an array, nil without one
+	Rest *Identifier
+	Body *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
@@ -385,28 +815,49 @@ func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
 
+func (fl *FunctionLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
-	params := []string{}
-
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(FormatParameters(fl.Parameters, fl.Defaults, fl.Rest))
 	out.WriteString(")")
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
 
+// the parameter list as written, "x, y = 2, ...rest"
+func FormatParameters(params []*Identifier, defaults []Expression, rest *Identifier) string {
+	out := []string{}
+	required := len(params) - len(defaults)
+
+	for i, p := range params {
+		if i < required {
+			out = append(out, p.String())
+		} else {
+			out = append(out, p.String()+" = "+defaults[i-required].String())
+		}
+	}
+
+	if rest != nil {
+		out = append(out, "..."+rest.String())
+	}
+
+	return strings.Join(out, ", ")
+}
+
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	// set by the parser when the call is the last thing its enclosing
+	// function does, so the evaluator can reuse the caller's Go stack frame
+	Tail bool
 }
 
 func (ce *CallExpression) expressionNode() {}
@@ -415,6 +866,10 @@ func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
 
+func (ce *CallExpression) Pos() token.Position {
+	return ce.Token.Pos
+}
+
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
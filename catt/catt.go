@@ -0,0 +1,220 @@
+// Package catt runs catt programs from Go.
+//
+//	interp := catt.New(catt.WithStdout(&buf))
+//	interp.SetGlobal("name", "tom")
+//	result, err := interp.Run(`meowln("hi " + name); 1 + 2`)
+package catt
+
+import (
+	"fmt"
+	"go_interpreter/evaluator"
+	"go_interpreter/lexer"
+	"go_interpreter/object"
+	"go_interpreter/parser"
+	"go_interpreter/repl"
+	"go_interpreter/token"
+	"io"
+	"os"
+	"strings"
+)
+
+// an embedded catt program, globals are kept between calls to Run
+type Interpreter struct {
+	env    *object.Environment
+	stdout io.Writer
+	stderr io.Writer
+	file   string
+}
+
+type Option func(*Interpreter)
+
+// where meow and meowln write, os.Stdout by default
+func WithStdout(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.stdout = w
+	}
+}
+
+// where Run reports errors the way the catt command does, with the source
+// excerpt and traceback. Nothing is reported by default, the error returned
+// by Run carries the same information
+func WithStderr(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.stderr = w
+	}
+}
+
+// the file name positions point into, imports are resolved relative to it
+func WithFile(name string) Option {
+	return func(i *Interpreter) {
+		i.file = name
+	}
+}
+
+func New(opts ...Option) *Interpreter {
+	i := &Interpreter{env: object.NewEnvironment(), stdout: os.Stdout}
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	for name, builtin := range evaluator.PrintBuiltins(i.stdout) {
+		i.env.SetBuiltin(name, builtin)
+	}
+
+	return i
+}
+
+// evaluates src and returns the value of its last statement, which is nil
+// for statements without one. A *SyntaxError or *RuntimeError is returned
+// when src does not parse or stops with an error
+func (i *Interpreter) Run(src string) (object.Object, error) {
+	p := parser.New(lexer.NewWithFile(src, i.file))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		if i.stderr != nil {
+			repl.PrintParserErrors(i.stderr, src, p.ParseErrors())
+		}
+		return nil, &SyntaxError{Errors: p.ParseErrors()}
+	}
+
+	result := evaluator.Eval(program, i.env)
+	if err, ok := result.(*object.Error); ok {
+		if i.stderr != nil {
+			repl.PrintRuntimeError(i.stderr, src, err)
+		}
+		return nil, &RuntimeError{Message: err.Message, Pos: err.Pos, Stack: err.Stack}
+	}
+
+	return result, nil
+}
+
+// binds name in the global scope, value goes through ToObject. A global the
+// program declared with const can not be replaced
+func (i *Interpreter) SetGlobal(name string, value interface{}) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+
+	if !i.env.Define(name, obj, false) {
+		return fmt.Errorf("cannot redeclare constant %s", name)
+	}
+	return nil
+}
+
+func (i *Interpreter) GetGlobal(name string) (object.Object, bool) {
+	return i.env.GetLocal(name)
+}
+
+// returned by Run when the source does not parse
+type SyntaxError struct {
+	Errors []*parser.ParseError
+}
+
+func (e *SyntaxError) Error() string {
+	msgs := []string{}
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// returned by Run when the program stops with an error, Stack holds the
+// calls it unwound through, most recent first
+type RuntimeError struct {
+	Message string
+	Pos     token.Position
+	Stack   []object.Frame
+}
+
+func (e *RuntimeError) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Message
+	}
+	return e.Pos.String() + ": " + e.Message
+}
+
+// converts a Go value to its catt object. Slices and maps are converted
+// element by element and Go functions taking and returning objects become
+// builtins, objects are passed through as they are
+func ToObject(value interface{}) (object.Object, error) {
+	switch value := value.(type) {
+	case nil:
+		return evaluator.NULL, nil
+	case object.Object:
+		return value, nil
+	case bool:
+		return evaluator.NativeBool(value), nil
+	case int:
+		return &object.Integer{Value: int64(value)}, nil
+	case int32:
+		return &object.Integer{Value: int64(value)}, nil
+	case int64:
+		return &object.Integer{Value: value}, nil
+	case float32:
+		return &object.Float{Value: float64(value)}, nil
+	case float64:
+		return &object.Float{Value: value}, nil
+	case string:
+		return &object.String{Value: value}, nil
+	case func(args ...object.Object) object.Object:
+		return &object.BuiltIn{Fn: value}, nil
+	case object.BuiltInFunction:
+		return &object.BuiltIn{Fn: value}, nil
+	case []interface{}:
+		elements := make([]object.Object, 0, len(value))
+		for _, el := range value {
+			obj, err := ToObject(el)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, obj)
+		}
+		return &object.Array{Elements: elements}, nil
+	case map[string]interface{}:
+		pairs := make(map[object.HashKey]object.HashPair)
+		for k, v := range value {
+			val, err := ToObject(v)
+			if err != nil {
+				return nil, err
+			}
+			key := &object.String{Value: k}
+			pairs[key.HashKey()] = object.HashPair{Key: key, Value: val}
+		}
+		return &object.Hash{Pairs: pairs}, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %T to a catt value", value)
+	}
+}
+
+// converts a catt object to the matching Go value: int64, float64, string,
+// bool, nil, []interface{} and map[interface{}]interface{}. Anything else,
+// like functions, is returned as the object itself
+func ToGo(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Array:
+		elements := make([]interface{}, 0, len(obj.Elements))
+		for _, el := range obj.Elements {
+			elements = append(elements, ToGo(el))
+		}
+		return elements
+	case *object.Hash:
+		pairs := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			pairs[ToGo(pair.Key)] = ToGo(pair.Value)
+		}
+		return pairs
+	default:
+		return obj
+	}
+}
@@ -0,0 +1,121 @@
+package catt
+
+import (
+	"bytes"
+	"errors"
+	"go_interpreter/object"
+	"strings"
+	"testing"
+)
+
+func TestRunKeepsGlobals(t *testing.T) {
+	var out bytes.Buffer
+	interp := New(WithStdout(&out))
+
+	if _, err := interp.Run(`var greeting = "hi";`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := interp.Run(`meowln(greeting + " " + name); 1 + 2`)
+	if err == nil {
+		t.Fatalf("expected an error for the missing name")
+	}
+
+	if err := interp.SetGlobal("name", "tom"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err = interp.Run(`meowln(greeting + " " + name); 1 + 2`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if ToGo(result) != int64(3) {
+		t.Errorf("wrong result. got=%v", ToGo(result))
+	}
+
+	if out.String() != "hi tom\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+func TestSetAndGetGlobal(t *testing.T) {
+	interp := New()
+
+	double := func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	}
+
+	globals := map[string]interface{}{
+		"n":      4,
+		"scores": []interface{}{1, 2.5, "three", true, nil},
+		"cat":    map[string]interface{}{"name": "tom"},
+		"double": double,
+	}
+	for name, value := range globals {
+		if err := interp.SetGlobal(name, value); err != nil {
+			t.Fatalf("SetGlobal(%q) failed: %s", name, err)
+		}
+	}
+
+	if _, err := interp.Run(`var result = [double(n), scores[1], cat["name"]];`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, ok := interp.GetGlobal("result")
+	if !ok {
+		t.Fatalf("global result not found")
+	}
+
+	got := ToGo(result).([]interface{})
+	if got[0] != int64(8) || got[1] != 2.5 || got[2] != "tom" {
+		t.Errorf("wrong result. got=%v", got)
+	}
+
+	if err := interp.SetGlobal("ch", make(chan int)); err == nil {
+		t.Errorf("expected an error for an unsupported value")
+	}
+
+	if _, err := interp.Run(`const limit = 3;`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := interp.SetGlobal("limit", 4); err == nil {
+		t.Errorf("expected an error for replacing a constant")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var stderr bytes.Buffer
+	interp := New(WithStderr(&stderr), WithFile("script.catt"))
+
+	_, err := interp.Run(`var f = fn() { 1 / 0 };
+f();`)
+
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("error is not *RuntimeError. got=%T (%v)", err, err)
+	}
+
+	if err.Error() != "script.catt:1:18: division by zero: 1 / 0" {
+		t.Errorf("wrong error message. got=%q", err.Error())
+	}
+
+	if len(runtimeErr.Stack) != 1 || runtimeErr.Stack[0].Function != "f" {
+		t.Errorf("wrong stack. got=%v", runtimeErr.Stack)
+	}
+
+	if !strings.Contains(stderr.String(), "traceback (most recent call first):") {
+		t.Errorf("error was not reported on stderr. got=%q", stderr.String())
+	}
+
+	_, err = interp.Run(`var = 1;`)
+
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("error is not *SyntaxError. got=%T (%v)", err, err)
+	}
+
+	if len(syntaxErr.Errors) == 0 {
+		t.Errorf("syntax error has no parser errors")
+	}
+}
@@ -0,0 +1,285 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i += 1
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n",
+			len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	OpGreaterEqual
+	OpLessEqual
+
+	OpMinus
+	OpBang
+	// replaces the top of the stack with TRUE or FALSE by its truthiness
+	OpTruthy
+	// fails unless the top of the stack is a BOOLEAN, loops require one
+	OpCheckBool
+
+	OpJump
+	OpJumpNotTruthy
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	// like OpSetGlobal and OpSetLocal for a var or const declaration, the
+	// second operand is 1 for const. Both fail when the slot holds a constant
+	OpDefineGlobal
+	OpDefineLocal
+	// pushes the cell behind a local so a closure can capture it
+	OpGetLocalCell
+	// puts a new empty cell behind a local, a block does this for its vars
+	// every time it runs. The second operand is the STRING constant naming
+	// the var, blocks take turns using the same slots
+	OpFreshLocal
+	// puts a new cell holding the same value behind a local, see compileFor
+	OpCopyLocal
+	OpGetFree
+	OpSetFree
+	// pushes the cell behind a free variable for a nested closure
+	OpGetFreeCell
+
+	OpArray
+	OpHash
+	OpIndex
+	// pops high, low and the sliced value, a NULL bound was left out
+	OpSlice
+	// pops value, index and container, stores the value and pushes it back
+	OpSetIndex
+	// pushes copies of the top two values
+	OpDup2
+	// pushes a copy of the top value
+	OpDup
+
+	// the operand is the STRING constant naming the member
+	OpGetMember
+	// pops value and record, stores the value and pushes it back
+	OpSetMember
+
+	OpCall
+	OpTailCall
+	OpReturnValue
+	OpReturn
+	OpClosure
+
+	// raises the STRING constant at the operand as a runtime error
+	OpError
+	// pops a value and raises it as a runtime error, see evaluator.ThrowOperation
+	OpThrow
+	// installs a handler, errors raised until the matching OpEndTry jump to
+	// the operand with the error on the stack
+	OpTry
+	OpEndTry
+	// replaces the error a handler pushed with the value catch binds
+	OpCatch
+
+	// pops a literal pattern and a value, pushes whether the value matches
+	OpMatchValue
+	// pops a value, pushes whether it is an array with operand elements
+	OpMatchArray
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd: {"OpAdd", []int{}},
+	OpSub: {"OpSub", []int{}},
+	OpMul: {"OpMul", []int{}},
+	OpDiv: {"OpDiv", []int{}},
+	OpMod: {"OpMod", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+
+	OpMinus:     {"OpMinus", []int{}},
+	OpBang:      {"OpBang", []int{}},
+	OpTruthy:    {"OpTruthy", []int{}},
+	OpCheckBool: {"OpCheckBool", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+
+	OpGetGlobal:    {"OpGetGlobal", []int{2}},
+	OpSetGlobal:    {"OpSetGlobal", []int{2}},
+	OpGetLocal:     {"OpGetLocal", []int{1}},
+	OpSetLocal:     {"OpSetLocal", []int{1}},
+	OpDefineGlobal: {"OpDefineGlobal", []int{2, 1}},
+	OpDefineLocal:  {"OpDefineLocal", []int{1, 1}},
+	OpGetLocalCell: {"OpGetLocalCell", []int{1}},
+	OpFreshLocal:   {"OpFreshLocal", []int{1, 2}},
+	OpCopyLocal:    {"OpCopyLocal", []int{1}},
+	OpGetFree:      {"OpGetFree", []int{1}},
+	OpSetFree:      {"OpSetFree", []int{1}},
+	OpGetFreeCell:  {"OpGetFreeCell", []int{1}},
+
+	OpArray: {"OpArray", []int{2}},
+	OpHash:  {"OpHash", []int{2}},
+	OpIndex: {"OpIndex", []int{}},
+	OpSlice: {"OpSlice", []int{}},
+
+	OpSetIndex: {"OpSetIndex", []int{}},
+	OpDup2:     {"OpDup2", []int{}},
+	OpDup:      {"OpDup", []int{}},
+
+	OpGetMember: {"OpGetMember", []int{2}},
+	OpSetMember: {"OpSetMember", []int{2}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpTailCall:    {"OpTailCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	OpClosure:     {"OpClosure", []int{2, 1}},
+
+	OpError: {"OpError", []int{2}},
+	OpThrow: {"OpThrow", []int{}},
+
+	OpTry:    {"OpTry", []int{2}},
+	OpEndTry: {"OpEndTry", []int{}},
+	OpCatch:  {"OpCatch", []int{}},
+
+	OpMatchValue: {"OpMatchValue", []int{}},
+	OpMatchArray: {"OpMatchArray", []int{2}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// encodes an instruction, operands are big endian with the widths from
+// the opcode's Definition
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 { return uint8(ins[0]) }
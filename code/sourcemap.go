@@ -0,0 +1,35 @@
+package code
+
+import "go_interpreter/token"
+
+// maps instruction offsets back to the source position they were compiled
+// from, entries are appended in offset order
+type SourceMap []SourceMapEntry
+
+type SourceMapEntry struct {
+	Offset int
+	Pos    token.Position
+}
+
+// records pos for the instruction at offset, repeats of the previous
+// position are skipped
+func (sm SourceMap) Add(offset int, pos token.Position) SourceMap {
+	if n := len(sm); n > 0 && sm[n-1].Pos == pos {
+		return sm
+	}
+	return append(sm, SourceMapEntry{Offset: offset, Pos: pos})
+}
+
+// the position of the instruction at offset, zero when unknown
+func (sm SourceMap) Lookup(offset int) token.Position {
+	var pos token.Position
+
+	for _, entry := range sm {
+		if entry.Offset > offset {
+			break
+		}
+		pos = entry.Pos
+	}
+
+	return pos
+}
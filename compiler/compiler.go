@@ -0,0 +1,1216 @@
+package compiler
+
+import (
+	"fmt"
+	"go_interpreter/ast"
+	"go_interpreter/code"
+	"go_interpreter/evaluator"
+	"go_interpreter/object"
+	"go_interpreter/token"
+)
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	sourceMap           code.SourceMap
+
+	// loops currently being compiled, innermost last
+	loops []*loopContext
+
+	// try expressions whose block or catch is being compiled, innermost last
+	tries []*tryContext
+}
+
+// jumps waiting for a loop to finish compiling so they know where to go
+type loopContext struct {
+	label     string
+	breaks    []int
+	continues []int
+}
+
+// what a return, break or continue leaving a try has to do on the way out
+type tryContext struct {
+	// whether a handler is installed that has to be removed
+	handler bool
+	finally *ast.BlockStatement
+	// loops entered before the try, jumps to them leave it
+	loops int
+}
+
+// local slots are addressed by a one byte operand
+const maxLocals = 256
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// position of the node being compiled, recorded for every emitted instruction
+	pos token.Position
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	SourceMap    code.SourceMap
+	NumGlobals   int
+	GlobalNames  []string
+	// locals of the main frame, see SymbolTable.MainLocals
+	MainLocals []string
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewSymbolTable(),
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	if pos := node.Pos(); pos.IsValid() {
+		saved := c.pos
+		c.pos = pos
+		defer func() { c.pos = saved }()
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		c.hoist(node.Statements)
+		if err := c.compileStatements(node.Statements); err != nil {
+			return err
+		}
+		return c.checkLocals(len(c.symbolTable.MainLocals()))
+
+	case *ast.ExpressionStatement:
+		if node.Expression == nil {
+			return nil
+		}
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		c.symbolTable.EnterBlock()
+		for _, name := range declaredNames(node.Statements) {
+			c.defineBlock(name)
+		}
+		err := c.compileStatements(node.Statements)
+		c.symbolTable.LeaveBlock()
+		return err
+
+	case *ast.LetStatement:
+		symbol, ok := c.symbolTable.ResolveLocal(node.Name.Value)
+		if !ok {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+
+		if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+			if err := c.compileFunction(fn, node.Name.Value, false); err != nil {
+				return err
+			}
+		} else if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.defineSymbol(symbol, node.Constant())
+
+	case *ast.Identifier:
+		c.loadIdentifier(node.Value)
+
+	case *ast.AssignExpression:
+		return c.compileAssign(node)
+
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+
+	case *ast.String:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("%s: unknown operator %s", c.pos, node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogical(node)
+		}
+
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("%s: unknown operator %s", c.pos, node.Operator)
+		}
+		c.emit(op)
+
+	case *ast.IfExpression:
+		return c.compileIf(node)
+
+	case *ast.WhileExpression:
+		return c.compileWhile(node)
+
+	case *ast.ForExpression:
+		return c.compileFor(node)
+
+	case *ast.BreakStatement:
+		return c.compileLoopJump(node.Label, true)
+
+	case *ast.ContinueStatement:
+		return c.compileLoopJump(node.Label, false)
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if err := c.leaveTries(0); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+
+	case *ast.TryExpression:
+		return c.compileTry(node)
+
+	case *ast.MatchExpression:
+		return c.compileMatch(node)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunction(node, "", false)
+
+	case *ast.FunctionStatement:
+		// stored by hoistFunctions when the enclosing block started
+		return nil
+
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+
+		// tracebacks point at the function being called, like the evaluator's
+		c.pos = node.Function.Pos()
+		if node.Tail {
+			c.emit(code.OpTailCall, len(node.Arguments))
+		} else {
+			c.emit(code.OpCall, len(node.Arguments))
+		}
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, k := range node.Keys {
+			if err := c.Compile(k); err != nil {
+				return err
+			}
+			if err := c.Compile(node.Pairs[k]); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.MemberExpression:
+		if err := c.Compile(node.Object); err != nil {
+			return err
+		}
+		c.emit(code.OpGetMember, c.addConstant(&object.String{Value: node.Property.Value}))
+
+	case *ast.StructStatement:
+		symbol, ok := c.symbolTable.ResolveLocal(node.Name.Value)
+		if !ok {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+
+		fields := []string{}
+		for _, f := range node.Fields {
+			fields = append(fields, f.Value)
+		}
+		st := &object.StructType{Name: node.Name.Value, Fields: fields}
+
+		c.emit(code.OpConstant, c.addConstant(st))
+		c.defineSymbol(symbol, false)
+
+	case *ast.SliceExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		for _, bound := range []ast.Expression{node.Low, node.High} {
+			if bound == nil {
+				c.emit(code.OpNull)
+			} else if err := c.Compile(bound); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpSlice)
+
+	case *ast.ImportStatement:
+		return fmt.Errorf("%s: import is not supported by the vm backend, files that import modules only run on the evaluator", c.pos)
+
+	default:
+		return fmt.Errorf("%s: %T is not supported by the vm backend", c.pos, node)
+	}
+
+	return nil
+}
+
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+	">=": code.OpGreaterEqual,
+	"<=": code.OpLessEqual,
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+		NumGlobals:   c.symbolTable.NumDefinitions(),
+		GlobalNames:  c.symbolTable.Names(),
+		MainLocals:   c.symbolTable.MainLocals(),
+	}
+}
+
+// defines every var of a function body (or the program) up front, names are
+// looked up when the code runs in the evaluator so a function may refer to
+// a var declared after it
+func (c *Compiler) hoist(stmts []ast.Statement) {
+	for _, name := range declaredNames(stmts) {
+		if _, ok := c.symbolTable.ResolveLocal(name); !ok {
+			c.symbolTable.Define(name)
+		}
+	}
+}
+
+// defines name in the innermost block. Its local gets a new cell every time
+// the block runs, so closures made by an earlier run keep theirs
+func (c *Compiler) defineBlock(name string) Symbol {
+	symbol := c.symbolTable.DefineBlock(name)
+	c.emit(code.OpFreshLocal, symbol.Index, c.addConstant(&object.String{Value: name}))
+	return symbol
+}
+
+// names declared by stmts themselves, nested blocks and function literals
+// are left alone since they get their own scope
+func declaredNames(stmts []ast.Statement) []string {
+	names := []string{}
+
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			names = append(names, stmt.Name.Value)
+		case *ast.StructStatement:
+			names = append(names, stmt.Name.Value)
+		case *ast.FunctionStatement:
+			names = append(names, stmt.Name.Value)
+		}
+	}
+
+	return names
+}
+
+// the statements of a program, block or function body, with its fn
+// statements stored first
+func (c *Compiler) compileStatements(stmts []ast.Statement) error {
+	if err := c.hoistFunctions(stmts); err != nil {
+		return err
+	}
+
+	for _, s := range stmts {
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stores the fn statements of a program or block before its first
+// statement, their names are already defined by hoist. A fn can not share
+// its name with a const of the same scope
+func (c *Compiler) hoistFunctions(stmts []ast.Statement) error {
+	consts := constNames(stmts)
+
+	for _, stmt := range stmts {
+		fs, ok := stmt.(*ast.FunctionStatement)
+		if !ok {
+			continue
+		}
+
+		if consts[fs.Name.Value] {
+			saved := c.pos
+			c.pos = fs.Pos()
+			c.emitError("cannot redeclare constant " + fs.Name.Value)
+			c.pos = saved
+			return nil
+		}
+
+		symbol, ok := c.symbolTable.ResolveLocal(fs.Name.Value)
+		if !ok {
+			symbol = c.symbolTable.Define(fs.Name.Value)
+		}
+
+		saved := c.pos
+		c.pos = fs.Pos()
+		err := c.compileFunction(fs.Function, fs.Name.Value, true)
+		if err == nil {
+			c.defineSymbol(symbol, false)
+		}
+		c.pos = saved
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func constNames(stmts []ast.Statement) map[string]bool {
+	names := map[string]bool{}
+	for _, stmt := range stmts {
+		if ls, ok := stmt.(*ast.LetStatement); ok && ls.Constant() {
+			names[ls.Name.Value] = true
+		}
+	}
+	return names
+}
+
+func (c *Compiler) loadIdentifier(name string) {
+	symbol, ok := c.symbolTable.Resolve(name)
+	if ok {
+		c.loadSymbol(symbol)
+		return
+	}
+
+	if builtin, ok := evaluator.LookupBuiltin(name); ok {
+		c.emit(code.OpConstant, c.addConstant(builtin))
+		return
+	}
+
+	c.emitError("identifier not found: " + name)
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	}
+}
+
+// declarations go through the vm's constant check, symbols of a declaration
+// are never free
+func (c *Compiler) defineSymbol(s Symbol, constant bool) {
+	flag := 0
+	if constant {
+		flag = 1
+	}
+
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpDefineGlobal, s.Index, flag)
+	case LocalScope:
+		c.emit(code.OpDefineLocal, s.Index, flag)
+	}
+}
+
+// x = v leaves v on the stack, compound operators load x first
+func (c *Compiler) compileAssign(node *ast.AssignExpression) error {
+	switch target := node.Target.(type) {
+	case *ast.IndexExpression:
+		return c.compileIndexAssign(node, target)
+	case *ast.MemberExpression:
+		return c.compileMemberAssign(node, target)
+	}
+
+	name := node.Target.(*ast.Identifier).Value
+	symbol, ok := c.symbolTable.Resolve(name)
+
+	if node.Operator != "=" && ok {
+		c.loadSymbol(symbol)
+	}
+
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+
+	if !ok {
+		if node.Operator != "=" {
+			c.emitError("identifier not found: " + name)
+		} else {
+			c.emitError("cannot assign to undeclared identifier: " + name)
+		}
+		return nil
+	}
+
+	if node.Operator != "=" {
+		// "+=" becomes "+" and so on
+		op := infixOpcodes[node.Operator[:len(node.Operator)-1]]
+		c.emit(op)
+	}
+
+	c.storeSymbol(symbol)
+	c.loadSymbol(symbol)
+	return nil
+}
+
+// the container and index stay on the stack for OpSetIndex, compound
+// operators read the current element through a copy of them
+func (c *Compiler) compileIndexAssign(node *ast.AssignExpression, target *ast.IndexExpression) error {
+	if err := c.Compile(target.Left); err != nil {
+		return err
+	}
+	if err := c.Compile(target.Index); err != nil {
+		return err
+	}
+
+	if node.Operator != "=" {
+		c.emit(code.OpDup2)
+		c.emit(code.OpIndex)
+	}
+
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+
+	if node.Operator != "=" {
+		c.emit(infixOpcodes[node.Operator[:len(node.Operator)-1]])
+	}
+
+	c.emit(code.OpSetIndex)
+	return nil
+}
+
+func (c *Compiler) compileMemberAssign(node *ast.AssignExpression, target *ast.MemberExpression) error {
+	if err := c.Compile(target.Object); err != nil {
+		return err
+	}
+
+	name := c.addConstant(&object.String{Value: target.Property.Value})
+	if node.Operator != "=" {
+		c.emit(code.OpDup)
+		c.emit(code.OpGetMember, name)
+	}
+
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+
+	if node.Operator != "=" {
+		c.emit(infixOpcodes[node.Operator[:len(node.Operator)-1]])
+	}
+
+	c.emit(code.OpSetMember, name)
+	return nil
+}
+
+// && and || leave TRUE or FALSE, the right operand only runs when the left
+// one does not decide the result
+func (c *Compiler) compileLogical(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	if node.Operator == "||" {
+		c.emit(code.OpBang)
+	}
+	shortCircuit := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	c.emit(code.OpTruthy)
+	jumpEnd := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(shortCircuit, len(c.currentInstructions()))
+	if node.Operator == "||" {
+		c.emit(code.OpTrue)
+	} else {
+		c.emit(code.OpFalse)
+	}
+
+	c.changeOperand(jumpEnd, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileIf(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	jump := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthy, len(c.currentInstructions()))
+
+	switch {
+	case node.CondAlternative != nil:
+		if err := c.Compile(node.CondAlternative); err != nil {
+			return err
+		}
+	case node.Alternative != nil:
+		if err := c.compileBlockValue(node.Alternative); err != nil {
+			return err
+		}
+	default:
+		c.emit(code.OpNull)
+	}
+
+	c.changeOperand(jump, len(c.currentInstructions()))
+	return nil
+}
+
+// whether the last statement of block is an expression, whose value the
+// block then has
+func endsWithExpression(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	_, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+// compiles block so it leaves the value of its last expression on the stack,
+// or NULL when it does not end in one
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	start := len(c.currentInstructions())
+
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	last := c.scopes[c.scopeIndex].lastInstruction
+	if endsWithExpression(block) && last.Position >= start && last.Opcode == code.OpPop &&
+		len(c.currentInstructions()) > start {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileWhile(node *ast.WhileExpression) error {
+	conditionStart := len(c.currentInstructions())
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	c.emit(code.OpCheckBool)
+	exit := c.emit(code.OpJumpNotTruthy, 9999)
+
+	loop := c.enterLoop(node.Label)
+	if err := c.Compile(node.Consequence); err != nil {
+		return err
+	}
+	c.leaveLoop()
+
+	c.emit(code.OpJump, conditionStart)
+
+	c.patchLoop(loop, len(c.currentInstructions()), conditionStart)
+	c.changeOperand(exit, len(c.currentInstructions()))
+	c.emit(code.OpNull)
+	return nil
+}
+
+// the loop variables are defined in a block around the whole loop and get
+// new cells before every increment, so closures made by an iteration keep
+// the values of that iteration like in the evaluator
+func (c *Compiler) compileFor(node *ast.ForExpression) error {
+	c.symbolTable.EnterBlock()
+	defer c.symbolTable.LeaveBlock()
+
+	vars := []Symbol{}
+	for _, name := range declaredNames([]ast.Statement{node.Declaration}) {
+		vars = append(vars, c.defineBlock(name))
+	}
+
+	if err := c.Compile(node.Declaration); err != nil {
+		return err
+	}
+
+	conditionStart := len(c.currentInstructions())
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	c.emit(code.OpCheckBool)
+	exit := c.emit(code.OpJumpNotTruthy, 9999)
+
+	loop := c.enterLoop(node.Label)
+	if err := c.Compile(node.Consequence); err != nil {
+		return err
+	}
+	c.leaveLoop()
+
+	incrementStart := len(c.currentInstructions())
+	for _, v := range vars {
+		c.emit(code.OpCopyLocal, v.Index)
+	}
+	if err := c.Compile(node.Increment); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, conditionStart)
+
+	c.patchLoop(loop, len(c.currentInstructions()), incrementStart)
+	c.changeOperand(exit, len(c.currentInstructions()))
+	c.emit(code.OpNull)
+	return nil
+}
+
+func (c *Compiler) enterLoop(label *ast.Identifier) *loopContext {
+	loop := &loopContext{}
+	if label != nil {
+		loop.label = label.Value
+	}
+
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, loop)
+	return loop
+}
+
+func (c *Compiler) leaveLoop() {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+}
+
+func (c *Compiler) patchLoop(loop *loopContext, end int, next int) {
+	for _, pos := range loop.breaks {
+		c.changeOperand(pos, end)
+	}
+	for _, pos := range loop.continues {
+		c.changeOperand(pos, next)
+	}
+}
+
+// break and continue are jumps out of the innermost loop, or the one with
+// the matching label. Without one the evaluator's runtime error is raised
+func (c *Compiler) compileLoopJump(label *ast.Identifier, isBreak bool) error {
+	keyword := "continue"
+	if isBreak {
+		keyword = "break"
+	}
+
+	loops := c.scopes[c.scopeIndex].loops
+	for i := len(loops) - 1; i >= 0; i-- {
+		loop := loops[i]
+		if label != nil && loop.label != label.Value {
+			continue
+		}
+
+		if err := c.leaveTries(c.triesAfterLoop(i)); err != nil {
+			return err
+		}
+
+		pos := c.emit(code.OpJump, 9999)
+		if isBreak {
+			loop.breaks = append(loop.breaks, pos)
+		} else {
+			loop.continues = append(loop.continues, pos)
+		}
+		return nil
+	}
+
+	if label != nil {
+		c.emitError(fmt.Sprintf("%s label not found: %s", keyword, label.Value))
+	} else {
+		c.emitError(keyword + " outside of loop")
+	}
+	return nil
+}
+
+// the value being matched stays on the stack while the arms are tried. Each
+// arm runs the tests of its pattern, stores the bindings once all of them
+// passed and evaluates its body. The bindings and the vars of the body are
+// defined in a block of their own, like the evaluator's arm environment
+func (c *Compiler) compileMatch(node *ast.MatchExpression) error {
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+
+	jumps := []int{}
+	for _, arm := range node.Arms {
+		fails := []int{}
+		if err := c.compilePatternTests(arm.Pattern, nil, &fails); err != nil {
+			return err
+		}
+
+		c.symbolTable.EnterBlock()
+		c.compilePatternBindings(arm.Pattern, nil)
+		c.emit(code.OpPop)
+
+		err := c.compileBlockValue(arm.Body)
+		c.symbolTable.LeaveBlock()
+		if err != nil {
+			return err
+		}
+
+		jumps = append(jumps, c.emit(code.OpJump, 9999))
+		for _, fail := range fails {
+			c.changeOperand(fail, len(c.currentInstructions()))
+		}
+	}
+
+	c.emit(code.OpPop)
+	c.emit(code.OpNull)
+
+	for _, jump := range jumps {
+		c.changeOperand(jump, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// pushes the part of the matched value at path, a list of array indices
+func (c *Compiler) loadMatchPath(path []int) {
+	c.emit(code.OpDup)
+	for _, idx := range path {
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: int64(idx)}))
+		c.emit(code.OpIndex)
+	}
+}
+
+// jumps to one of fails, patched by the caller, as soon as a test fails.
+// Array lengths are tested before their elements so indexing cannot fail
+func (c *Compiler) compilePatternTests(pattern ast.Expression, path []int, fails *[]int) error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return nil
+
+	case *ast.ArrayLiteral:
+		c.loadMatchPath(path)
+		c.emit(code.OpMatchArray, len(pattern.Elements))
+		*fails = append(*fails, c.emit(code.OpJumpNotTruthy, 9999))
+
+		for i, el := range pattern.Elements {
+			elPath := append(append([]int{}, path...), i)
+			if err := c.compilePatternTests(el, elPath, fails); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		c.loadMatchPath(path)
+		if err := c.Compile(pattern); err != nil {
+			return err
+		}
+		c.emit(code.OpMatchValue)
+		*fails = append(*fails, c.emit(code.OpJumpNotTruthy, 9999))
+		return nil
+	}
+}
+
+func (c *Compiler) compilePatternBindings(pattern ast.Expression, path []int) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value == "_" {
+			return
+		}
+		symbol := c.defineBlock(pattern.Value)
+		c.loadMatchPath(path)
+		c.defineSymbol(symbol, false)
+
+	case *ast.ArrayLiteral:
+		for i, el := range pattern.Elements {
+			c.compilePatternBindings(el, append(append([]int{}, path...), i))
+		}
+	}
+}
+
+// the block's value, or the catch block's when the block raised an error.
+// finally is compiled once for every way out: after the block, after the
+// catch, before rethrowing an error nobody caught and before each return,
+// break or continue leaving the try (see leaveTries)
+func (c *Compiler) compileTry(node *ast.TryExpression) error {
+	jumps := []int{}
+
+	handler := c.emit(code.OpTry, 9999)
+	c.enterTry(true, node.Finally)
+	if err := c.compileBlockValue(node.Block); err != nil {
+		return err
+	}
+	c.leaveTry()
+	c.emit(code.OpEndTry)
+	if err := c.compileFinally(node.Finally); err != nil {
+		return err
+	}
+	jumps = append(jumps, c.emit(code.OpJump, 9999))
+	c.changeOperand(handler, len(c.currentInstructions()))
+
+	if node.Catch != nil {
+		// the param is only seen by the catch block
+		c.symbolTable.EnterBlock()
+		c.emit(code.OpCatch)
+		c.defineSymbol(c.defineBlock(node.Param.Value), false)
+
+		// errors raised by the catch block still have to run finally
+		if node.Finally != nil {
+			handler = c.emit(code.OpTry, 9999)
+		}
+		c.enterTry(node.Finally != nil, node.Finally)
+		err := c.compileBlockValue(node.Catch)
+		c.leaveTry()
+		c.symbolTable.LeaveBlock()
+		if err != nil {
+			return err
+		}
+
+		if node.Finally != nil {
+			c.emit(code.OpEndTry)
+			if err := c.compileFinally(node.Finally); err != nil {
+				return err
+			}
+			jumps = append(jumps, c.emit(code.OpJump, 9999))
+			c.changeOperand(handler, len(c.currentInstructions()))
+		}
+	}
+
+	if node.Finally != nil {
+		if err := c.compileFinally(node.Finally); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	}
+
+	for _, jump := range jumps {
+		c.changeOperand(jump, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// finally leaves nothing on the stack, its value is dropped
+func (c *Compiler) compileFinally(finally *ast.BlockStatement) error {
+	if finally == nil {
+		return nil
+	}
+	return c.Compile(finally)
+}
+
+func (c *Compiler) enterTry(handler bool, finally *ast.BlockStatement) {
+	scope := &c.scopes[c.scopeIndex]
+	try := &tryContext{handler: handler, finally: finally, loops: len(scope.loops)}
+	scope.tries = append(scope.tries, try)
+}
+
+func (c *Compiler) leaveTry() {
+	scope := &c.scopes[c.scopeIndex]
+	scope.tries = scope.tries[:len(scope.tries)-1]
+}
+
+// how many of the enclosing tries were entered before the loop at index
+func (c *Compiler) triesAfterLoop(index int) int {
+	tries := c.scopes[c.scopeIndex].tries
+	for i, try := range tries {
+		if try.loops > index {
+			return i
+		}
+	}
+	return len(tries)
+}
+
+// removes the handlers and runs the finally blocks of the tries being left,
+// innermost first, all but the first keep tries are left. A finally is
+// compiled as if its own try was already left, so a return inside it does
+// not run it again
+func (c *Compiler) leaveTries(keep int) error {
+	tries := c.scopes[c.scopeIndex].tries
+
+	for i := len(tries) - 1; i >= keep; i-- {
+		if tries[i].handler {
+			c.emit(code.OpEndTry)
+		}
+		if tries[i].finally == nil {
+			continue
+		}
+
+		c.scopes[c.scopeIndex].tries = append([]*tryContext{}, tries[:i]...)
+		err := c.compileFinally(tries[i].finally)
+		c.scopes[c.scopeIndex].tries = tries
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileFunction(node *ast.FunctionLiteral, name string, declared bool) error {
+	c.enterScope()
+
+	params := []Symbol{}
+	for _, p := range node.Parameters {
+		params = append(params, c.symbolTable.Define(p.Value))
+	}
+	if node.Rest != nil {
+		c.symbolTable.Define(node.Rest.Value)
+	}
+	c.hoist(node.Body.Statements)
+
+	// the defaults come first, a call skips the ones it has arguments for
+	var entryPoints []int
+	if len(node.Defaults) > 0 {
+		required := len(node.Parameters) - len(node.Defaults)
+		for i, def := range node.Defaults {
+			entryPoints = append(entryPoints, len(c.currentInstructions()))
+			if err := c.Compile(def); err != nil {
+				return err
+			}
+			c.defineSymbol(params[required+i], false)
+		}
+		entryPoints = append(entryPoints, len(c.currentInstructions()))
+	}
+
+	// the body shares the scope of the parameters
+	if err := c.compileStatements(node.Body.Statements); err != nil {
+		return err
+	}
+
+	if endsWithExpression(node.Body) && c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	freeNames := c.symbolTable.freeNames()
+	numLocals := c.symbolTable.NumDefinitions()
+	if err := c.checkLocals(numLocals); err != nil {
+		return err
+	}
+	localNames := c.symbolTable.Names()
+	sourceMap := c.scopes[c.scopeIndex].sourceMap
+	instructions := c.leaveScope()
+
+	// closures share the cell behind each captured variable
+	for _, s := range freeSymbols {
+		switch s.Scope {
+		case LocalScope:
+			c.emit(code.OpGetLocalCell, s.Index)
+		case FreeScope:
+			c.emit(code.OpGetFreeCell, s.Index)
+		}
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		NumDefaults:   len(node.Defaults),
+		Variadic:      node.Rest != nil,
+		EntryPoints:   entryPoints,
+		Name:          name,
+		Declared:      declared,
+		SourceMap:     sourceMap,
+		LocalNames:    localNames,
+		FreeNames:     freeNames,
+	}
+
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+	return nil
+}
+
+func (c *Compiler) checkLocals(n int) error {
+	if n > maxLocals {
+		return fmt.Errorf("%s: too many local variables: %d, the vm supports %d", c.pos, n, maxLocals)
+	}
+	return nil
+}
+
+func (c *Compiler) emitError(msg string) {
+	c.emit(code.OpError, c.addConstant(&object.String{Value: msg}))
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	scope := &c.scopes[c.scopeIndex]
+	scope.sourceMap = scope.sourceMap.Add(pos, c.pos)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	scope := &c.scopes[c.scopeIndex]
+	last := scope.lastInstruction
+	previous := scope.previousInstruction
+
+	scope.instructions = scope.instructions[:last.Position]
+	scope.lastInstruction = previous
+
+	// drop the entry of the removed instruction so the next one records its own
+	for len(scope.sourceMap) > 0 && scope.sourceMap[len(scope.sourceMap)-1].Offset >= last.Position {
+		scope.sourceMap = scope.sourceMap[:len(scope.sourceMap)-1]
+	}
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
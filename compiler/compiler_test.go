@@ -0,0 +1,246 @@
+package compiler
+
+import (
+	"fmt"
+	"go_interpreter/ast"
+	"go_interpreter/code"
+	"go_interpreter/lexer"
+	"go_interpreter/object"
+	"go_interpreter/parser"
+	"strings"
+	"testing"
+)
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func concatInstructions(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func TestCompileInstructions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []code.Instructions
+	}{
+		{
+			"1 + 2;",
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"var x = 1; x += 2;",
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpDefineGlobal, 0, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"if (true) { 10 }; 3333;",
+			[]code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"while (false) { break; }",
+			[]code.Instructions{
+				code.Make(code.OpFalse),
+				code.Make(code.OpCheckBool),
+				code.Make(code.OpJumpNotTruthy, 11),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpJump, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"try { 1 } catch (e) { 2 }",
+			[]code.Instructions{
+				code.Make(code.OpTry, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpEndTry),
+				code.Make(code.OpJump, 21),
+				code.Make(code.OpCatch),
+				code.Make(code.OpFreshLocal, 0, 1),
+				code.Make(code.OpDefineLocal, 0, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"try { 1 } finally { 2 }",
+			[]code.Instructions{
+				code.Make(code.OpTry, 14),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpEndTry),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 19),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpPop),
+				code.Make(code.OpThrow),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		c := New()
+		if err := c.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		expected := concatInstructions(tt.expected)
+		actual := c.Bytecode().Instructions
+		if actual.String() != expected.String() {
+			t.Errorf("wrong instructions for %q.\nwant=\n%s\ngot=\n%s",
+				tt.input, expected, actual)
+		}
+	}
+}
+
+func TestClosuresCaptureCells(t *testing.T) {
+	input := `fn(a) { fn(b) { a + b } }`
+
+	c := New()
+	if err := c.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	constants := c.Bytecode().Constants
+	inner, ok := constants[0].(*object.CompiledFunction)
+	if !ok {
+		t.Fatalf("constant 0 is not CompiledFunction. got=%T", constants[0])
+	}
+	outer := constants[1].(*object.CompiledFunction)
+
+	expectedInner := concatInstructions([]code.Instructions{
+		code.Make(code.OpGetFree, 0),
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpAdd),
+		code.Make(code.OpReturnValue),
+	})
+	if inner.Instructions.String() != expectedInner.String() {
+		t.Errorf("wrong inner instructions.\nwant=\n%s\ngot=\n%s", expectedInner, inner.Instructions)
+	}
+
+	expectedOuter := concatInstructions([]code.Instructions{
+		code.Make(code.OpGetLocalCell, 0),
+		code.Make(code.OpClosure, 0, 1),
+		code.Make(code.OpReturnValue),
+	})
+	if outer.Instructions.String() != expectedOuter.String() {
+		t.Errorf("wrong outer instructions.\nwant=\n%s\ngot=\n%s", expectedOuter, outer.Instructions)
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	first := NewEnclosedSymbolTable(global)
+	first.Define("b")
+
+	second := NewEnclosedSymbolTable(first)
+	second.Define("c")
+
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: FreeScope, Index: 0},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+	}
+
+	for name, sym := range expected {
+		result, ok := second.Resolve(name)
+		if !ok {
+			t.Errorf("name %s not resolvable", name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", name, sym, result)
+		}
+	}
+
+	if _, ok := second.Resolve("d"); ok {
+		t.Errorf("name d resolved, but was never defined")
+	}
+
+	if len(second.FreeSymbols) != 1 || second.FreeSymbols[0].Scope != LocalScope {
+		t.Errorf("wrong free symbols. got=%+v", second.FreeSymbols)
+	}
+}
+
+func TestUnsupportedNodes(t *testing.T) {
+	c := New()
+	err := c.Compile(parse(`import "lib.catt" as lib;`))
+	if err == nil {
+		t.Fatalf("expected a compile error for import")
+	}
+
+	if !strings.Contains(err.Error(), "import is not supported by the vm backend") {
+		t.Errorf("wrong error message. got=%q", err)
+	}
+}
+
+func TestBlocksShareSlots(t *testing.T) {
+	global := NewSymbolTable()
+	fn := NewEnclosedSymbolTable(global)
+	fn.Define("a")
+
+	fn.EnterBlock()
+	first := fn.DefineBlock("b")
+	fn.LeaveBlock()
+
+	fn.EnterBlock()
+	second := fn.DefineBlock("c")
+	fn.LeaveBlock()
+
+	if first.Index != 1 || second.Index != 1 {
+		t.Errorf("blocks did not share a slot. got=%d and %d", first.Index, second.Index)
+	}
+	if fn.NumDefinitions() != 2 {
+		t.Errorf("wrong number of slots. got=%d", fn.NumDefinitions())
+	}
+}
+
+func TestTooManyLocals(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("var f = fn() {")
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&input, " var v%d = %d;", i, i)
+	}
+	input.WriteString(" };")
+
+	err := New().Compile(parse(input.String()))
+	if err == nil {
+		t.Fatalf("expected a compile error for 300 locals")
+	}
+
+	if !strings.Contains(err.Error(), "too many local variables: 300") {
+		t.Errorf("wrong error message. got=%q", err)
+	}
+}
@@ -0,0 +1,203 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+	FreeScope   SymbolScope = "FREE"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// one table per function being compiled, Outer points at the enclosing
+// function (or the global table)
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	names          []string
+
+	FreeSymbols []Symbol
+
+	// blocks opened with EnterBlock, innermost last
+	blocks []block
+
+	// the locals the global table gives the program's blocks, names has one
+	// entry for every slot ever used
+	numMainLocals int
+	mainLocals    []string
+}
+
+type block struct {
+	// the symbols the block's definitions shadowed, nil when the name was
+	// not defined
+	shadowed map[string]*Symbol
+	// the first slot of the block, its slots are handed back when it ends
+	start int
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	free := []Symbol{}
+	return &SymbolTable{store: s, FreeSymbols: free}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+func (s *SymbolTable) Define(name string) Symbol {
+	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
+	s.store[name] = symbol
+	s.names = setName(s.names, symbol.Index, name)
+	s.numDefinitions++
+	return symbol
+}
+
+// names[index] = name, growing names when the slot is new
+func setName(names []string, index int, name string) []string {
+	if index < len(names) {
+		names[index] = name
+		return names
+	}
+	return append(names, name)
+}
+
+func (s *SymbolTable) EnterBlock() {
+	start := s.numDefinitions
+	if s.Outer == nil {
+		start = s.numMainLocals
+	}
+	s.blocks = append(s.blocks, block{shadowed: map[string]*Symbol{}, start: start})
+}
+
+// puts back the symbols shadowed by the block and frees its slots for the
+// next block. The vm gives every block a new cell for each of its vars when
+// it starts, so sharing a slot never mixes up their values
+func (s *SymbolTable) LeaveBlock() {
+	b := s.blocks[len(s.blocks)-1]
+	s.blocks = s.blocks[:len(s.blocks)-1]
+
+	for name, shadowed := range b.shadowed {
+		if shadowed == nil {
+			delete(s.store, name)
+		} else {
+			s.store[name] = *shadowed
+		}
+	}
+
+	if s.Outer == nil {
+		s.numMainLocals = b.start
+	} else {
+		s.numDefinitions = b.start
+	}
+}
+
+// defines name in the innermost block, in a new slot so a variable of the
+// same name outside the block is left alone. Defining it twice in the same
+// block gives the same symbol
+func (s *SymbolTable) DefineBlock(name string) Symbol {
+	shadowed := s.blocks[len(s.blocks)-1].shadowed
+	if _, ok := shadowed[name]; ok {
+		return s.store[name]
+	}
+
+	if symbol, ok := s.store[name]; ok {
+		shadowed[name] = &symbol
+	} else {
+		shadowed[name] = nil
+	}
+
+	if s.Outer == nil {
+		return s.defineMainLocal(name)
+	}
+	return s.Define(name)
+}
+
+// the blocks of the program keep their vars on the stack of the main frame
+// rather than in globals, so they get cells like the locals of a function
+func (s *SymbolTable) defineMainLocal(name string) Symbol {
+	symbol := Symbol{Name: name, Scope: LocalScope, Index: s.numMainLocals}
+
+	s.store[name] = symbol
+	s.mainLocals = setName(s.mainLocals, symbol.Index, name)
+	s.numMainLocals++
+	return symbol
+}
+
+// names of the main frame's locals indexed by their slot, see Names
+func (s *SymbolTable) MainLocals() []string {
+	return s.mainLocals
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1}
+	symbol.Scope = FreeScope
+
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+// finds name in this table or any outer one, locals of enclosing functions
+// become free symbols of this one
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		obj, ok = s.Outer.Resolve(name)
+		if !ok {
+			return obj, ok
+		}
+
+		if obj.Scope == GlobalScope {
+			return obj, ok
+		}
+
+		free := s.defineFree(obj)
+		return free, true
+	}
+	return obj, ok
+}
+
+// only this table, used to tell a redeclaration from a new definition
+func (s *SymbolTable) ResolveLocal(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if !ok || obj.Scope == FreeScope {
+		return Symbol{}, false
+	}
+	return obj, ok
+}
+
+// names of the defined symbols indexed by their slot, a slot shared by blocks
+// has the name of the last one
+func (s *SymbolTable) Names() []string {
+	return s.names
+}
+
+// every slot ever used, blocks that ended still need theirs in the frame
+func (s *SymbolTable) NumDefinitions() int {
+	return len(s.names)
+}
+
+func (s *SymbolTable) freeNames() []string {
+	names := []string{}
+	for _, sym := range s.FreeSymbols {
+		names = append(names, sym.Name)
+	}
+	return names
+}
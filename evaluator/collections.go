@@ -0,0 +1,217 @@
+package evaluator
+
+import (
+	"go_interpreter/object"
+	"unicode/utf8"
+)
+
+// registered from init since map, filter, reduce and each call back into
+// applyFunction, which would make the builtins table depend on itself
+func init() {
+	for name, builtin := range collectionBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+var collectionBuiltins = map[string]*object.BuiltIn{
+	"len": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("supports 1 argument, got: %d", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Array:
+				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.String:
+				// strings are measured in runes, not bytes
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(len(arg.Pairs))}
+			default:
+				return newError("argument type is not supported: %s", arg.Type())
+			}
+		},
+	},
+	"push": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("supports 2 arguments, got: %d", len(args))
+			}
+
+			arr, err := arrayArgument("push", args[0])
+			if err != nil {
+				return err
+			}
+
+			// arrays are values, push hands back a new one
+			elements := make([]object.Object, len(arr.Elements), len(arr.Elements)+1)
+			copy(elements, arr.Elements)
+			return &object.Array{Elements: append(elements, args[1])}
+		},
+	},
+	"first": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("supports 1 argument, got: %d", len(args))
+			}
+
+			arr, err := arrayArgument("first", args[0])
+			if err != nil {
+				return err
+			}
+
+			if len(arr.Elements) == 0 {
+				return NULL
+			}
+			return arr.Elements[0]
+		},
+	},
+	"last": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("supports 1 argument, got: %d", len(args))
+			}
+
+			arr, err := arrayArgument("last", args[0])
+			if err != nil {
+				return err
+			}
+
+			if len(arr.Elements) == 0 {
+				return NULL
+			}
+			return arr.Elements[len(arr.Elements)-1]
+		},
+	},
+	"rest": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("supports 1 argument, got: %d", len(args))
+			}
+
+			arr, err := arrayArgument("rest", args[0])
+			if err != nil {
+				return err
+			}
+
+			if len(arr.Elements) == 0 {
+				return NULL
+			}
+
+			elements := make([]object.Object, len(arr.Elements)-1)
+			copy(elements, arr.Elements[1:])
+			return &object.Array{Elements: elements}
+		},
+	},
+	"map": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("supports 2 arguments, got: %d", len(args))
+			}
+
+			arr, err := arrayArgument("map", args[0])
+			if err != nil {
+				return err
+			}
+
+			elements := make([]object.Object, 0, len(arr.Elements))
+			for _, el := range arr.Elements {
+				result := callback("map", args[1], el)
+				if isError(result) {
+					return result
+				}
+				elements = append(elements, result)
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"filter": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("supports 2 arguments, got: %d", len(args))
+			}
+
+			arr, err := arrayArgument("filter", args[0])
+			if err != nil {
+				return err
+			}
+
+			elements := []object.Object{}
+			for _, el := range arr.Elements {
+				result := callback("filter", args[1], el)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					elements = append(elements, el)
+				}
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"reduce": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("supports 3 arguments, got: %d", len(args))
+			}
+
+			arr, err := arrayArgument("reduce", args[0])
+			if err != nil {
+				return err
+			}
+
+			// reduce(arr, initial, fn(acc, el))
+			acc := args[1]
+			for _, el := range arr.Elements {
+				acc = callback("reduce", args[2], acc, el)
+				if isError(acc) {
+					return acc
+				}
+			}
+
+			return acc
+		},
+	},
+	"each": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("supports 2 arguments, got: %d", len(args))
+			}
+
+			arr, err := arrayArgument("each", args[0])
+			if err != nil {
+				return err
+			}
+
+			for _, el := range arr.Elements {
+				result := callback("each", args[1], el)
+				if isError(result) {
+					return result
+				}
+			}
+
+			return NULL
+		},
+	},
+}
+
+func arrayArgument(name string, arg object.Object) (*object.Array, *object.Error) {
+	arr, ok := arg.(*object.Array)
+	if !ok {
+		return nil, newError("argument to `%s` must be ARRAY, got %s", name, arg.Type())
+	}
+	return arr, nil
+}
+
+// calls fn for a higher-order builtin, errors from fn come back with a
+// frame naming the builtin that made the call
+func callback(builtin string, fn object.Object, args ...object.Object) object.Object {
+	result := applyFunction(fn, args, object.Frame{Function: "<" + builtin + " callback>"})
+	if result == nil {
+		return NULL
+	}
+	return result
+}
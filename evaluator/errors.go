@@ -0,0 +1,80 @@
+package evaluator
+
+import (
+	"go_interpreter/ast"
+	"go_interpreter/object"
+)
+
+// what catch binds, an error turned into a plain value so it can be passed
+// around without aborting anything. The stack lists the calls the error
+// unwound through before it was caught, most recent first, and value is what
+// was thrown (null for errors the interpreter raised)
+var errorStruct = &object.StructType{Name: "Error", Fields: []string{"message", "stack", "value"}}
+
+func errorValue(err *object.Error) *object.Record {
+	stack := make([]object.Object, 0, len(err.Stack))
+	for _, frame := range err.Stack {
+		stack = append(stack, &object.String{Value: frame.String()})
+	}
+
+	var thrown object.Object = NULL
+	if err.Thrown != nil {
+		thrown = err.Thrown
+	}
+
+	message := &object.String{Value: err.Message}
+	return &object.Record{Struct: errorStruct, Values: []object.Object{message, &object.Array{Elements: stack}, thrown}}
+}
+
+// the error raised by throw, a caught error is thrown again with its message
+// and value, anything else that is not a string gets its Inspect as message
+func thrownError(val object.Object) *object.Error {
+	switch v := val.(type) {
+	case *object.String:
+		return &object.Error{Message: v.Value, Thrown: val}
+	case *object.Record:
+		if v.Struct == errorStruct {
+			err := &object.Error{Message: v.Values[0].Inspect()}
+			if v.Values[2] != NULL {
+				err.Thrown = v.Values[2]
+			}
+			return err
+		}
+	}
+
+	return &object.Error{Message: val.Inspect(), Thrown: val}
+}
+
+func evalThrowStatement(node *ast.ThrowStatement, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	return thrownError(val)
+}
+
+// the try evaluates to its block, or to the catch block when the block
+// failed. finally always runs and only changes the outcome when it fails,
+// returns, breaks or continues itself
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Block, env)
+
+	if err, ok := result.(*object.Error); ok && te.Catch != nil {
+		catchEnv := object.NewEnclosedEnvironment(env)
+		catchEnv.Set(te.Param.Value, errorValue(err))
+		result = Eval(te.Catch, catchEnv)
+	}
+
+	if te.Finally != nil {
+		finally := Eval(te.Finally, env)
+		if finally != nil {
+			switch finally.Type() {
+			case object.RETURN_VAL_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+				return finally
+			}
+		}
+	}
+
+	return result
+}
@@ -5,8 +5,23 @@ import (
 	"go_interpreter/ast"
 	"go_interpreter/object"
 	"go_interpreter/utils"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"unicode/utf8"
 )
 
+// how many frames replaced by tail calls a traceback can show
+const maxTailFrames = 64
+
+// how many frames a traceback keeps, the ones nearest to the error
+const maxTraceback = 64
+
+// how deeply calls can nest before a program fails with a stack overflow,
+// the same as vm.MaxFrames. Tail calls do not count
+const maxCallDepth = 1 << 14
+
 var (
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
@@ -14,63 +29,8 @@ var (
 )
 
 var builtins = map[string]*object.BuiltIn{
-	"meow": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("supports 1 argument, got: %d", len(args))
-			}
-
-			switch arg := args[0].(type) {
-			case *object.String:
-				fmt.Print(arg.Value)
-				return &object.String{Value: arg.Value}
-
-			case *object.Integer:
-				fmt.Print(arg.Inspect())
-				return &object.String{Value: arg.Inspect()}
-
-			case *object.Boolean:
-				fmt.Print(arg.Inspect())
-				return &object.String{Value: arg.Inspect()}
-
-			case *object.Array:
-				fmt.Print(arg.Inspect())
-				return &object.String{Value: arg.Inspect()}
-
-			default:
-				return newError("argument type is not supported: %s", arg.Type())
-
-			}
-		},
-	},
-	"meowln": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("supports 1 argument, got: %d", len(args))
-			}
-
-			switch arg := args[0].(type) {
-			case *object.String:
-				fmt.Println(arg.Value)
-				return &object.String{Value: arg.Value}
-
-			case *object.Integer:
-				fmt.Println(arg.Inspect())
-				return &object.String{Value: arg.Inspect()}
-
-			case *object.Boolean:
-				fmt.Println(arg.Inspect())
-				return &object.String{Value: arg.Inspect()}
-
-			case *object.Array:
-				fmt.Print(arg.Inspect())
-				return &object.String{Value: arg.Inspect()}
-
-			default:
-				return newError("argument type is not supported: %s", arg.Type())
-			}
-		},
-	},
+	"meow":   newPrintBuiltin(os.Stdout, false),
+	"meowln": newPrintBuiltin(os.Stdout, true),
 	"cattfusion": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
@@ -134,7 +94,62 @@ var builtins = map[string]*object.BuiltIn{
 	},
 }
 
+// meow and meowln writing to out instead of os.Stdout, for programs that
+// need their output somewhere else (see object.Environment.SetBuiltin)
+func PrintBuiltins(out io.Writer) map[string]*object.BuiltIn {
+	return map[string]*object.BuiltIn{
+		"meow":   newPrintBuiltin(out, false),
+		"meowln": newPrintBuiltin(out, true),
+	}
+}
+
+func newPrintBuiltin(out io.Writer, newline bool) *object.BuiltIn {
+	write := fmt.Fprint
+	if newline {
+		write = fmt.Fprintln
+	}
+
+	return &object.BuiltIn{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("supports 1 argument, got: %d", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.String:
+				write(out, arg.Value)
+				return &object.String{Value: arg.Value}
+
+			case *object.Integer, *object.Float, *object.Boolean, *object.Array, *object.Hash, *object.Record:
+				write(out, arg.Inspect())
+				return &object.String{Value: arg.Inspect()}
+
+			default:
+				return newError("argument type is not supported: %s", arg.Type())
+			}
+		},
+	}
+}
+
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+
+	// an expression always has a value, even an if whose block ends in a
+	// declaration, so builtins and operators never see nil
+	if _, ok := node.(ast.Expression); ok && result == nil {
+		return NULL
+	}
+
+	// errors bubble up through every enclosing Eval, only the innermost node
+	// gets to stamp its position
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.String:
 		return &object.String{Value: node.Value}
@@ -147,6 +162,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.Boolean:
 		return nativeToObjectBool(node.Value)
 
@@ -158,6 +176,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalPrefixExpression(node.Operator, right)
 
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
+
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -169,7 +191,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalInfixExpression(node.Operator, right, left)
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return evalBlockStatement(node, object.NewEnclosedEnvironment(env))
 
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
@@ -182,6 +204,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return evalIndexExpression(left, index)
 
+	case *ast.SliceExpression:
+		return evalSlice(node, env)
+
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -189,9 +214,30 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return &object.Array{Elements: elements}
 
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+
+	case *ast.MemberExpression:
+		return evalMemberExpression(node, env)
+
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+
+	case *ast.StructStatement:
+		return evalStructStatement(node, env)
+
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+
+	case *ast.ThrowStatement:
+		return evalThrowStatement(node, env)
+
 	case *ast.WhileExpression:
 		return evalWhileExpression(node, env)
 
@@ -199,15 +245,26 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalForExpression(node, env)
 
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
-			return val
-		}
-		env.Set(node.Name.Value, val)
+		return evalLetStatement(node, env)
 
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+
+	case *ast.BreakStatement:
+		if node.Label != nil {
+			return &object.Break{Label: node.Label.Value, Pos: node.Pos()}
+		}
+		return &object.Break{Pos: node.Pos()}
+
+	case *ast.ContinueStatement:
+		if node.Label != nil {
+			return &object.Continue{Label: node.Label.Value, Pos: node.Pos()}
+		}
+		return &object.Continue{Pos: node.Pos()}
+
 	case *ast.ReturnStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
@@ -216,9 +273,11 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return &object.ReturnValue{Value: val}
 
 	case *ast.FunctionLiteral:
-		params := node.Parameters
-		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return newFunction(node, "", env)
+
+	case *ast.FunctionStatement:
+		// bound by hoistFunctions when the enclosing block started
+		return nil
 
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
@@ -231,12 +290,74 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		if node.Tail {
+			return &object.TailCall{Function: function, Arguments: args, Frame: callFrame(node)}
+		}
+		return applyFunction(function, args, callFrame(node))
+	}
+
+	return nil
+}
+
+func newFunction(node *ast.FunctionLiteral, name string, env *object.Environment) *object.Function {
+	return &object.Function{
+		Name:       name,
+		Parameters: node.Parameters,
+		Defaults:   node.Defaults,
+		Rest:       node.Rest,
+		Env:        env,
+		Body:       node.Body,
+	}
+}
+
+// declares the names stmts bind before the first of them runs, like the
+// compiler gives them their slots up front. Using one before its
+// declaration is an error instead of finding an outer binding
+func hoistNames(stmts []ast.Statement, env *object.Environment) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			env.Hoist(stmt.Name.Value)
+		case *ast.StructStatement:
+			env.Hoist(stmt.Name.Value)
+		case *ast.FunctionStatement:
+			env.Hoist(stmt.Name.Value)
+		}
 	}
+}
 
+// binds the fn statements of a program or block before its first statement
+// runs, so they can be called from anywhere in it and call each other
+func hoistFunctions(stmts []ast.Statement, env *object.Environment) *object.Error {
+	consts := constNames(stmts)
+
+	for _, stmt := range stmts {
+		fs, ok := stmt.(*ast.FunctionStatement)
+		if !ok {
+			continue
+		}
+
+		// the fn is bound before a const of the same scope runs, Define
+		// alone would let the const replace it
+		if consts[fs.Name.Value] || !env.Define(fs.Name.Value, newFunction(fs.Function, fs.Name.Value, env), false) {
+			err := redeclaredConstant(fs.Name.Value)
+			err.Pos = fs.Pos()
+			return err
+		}
+	}
 	return nil
 }
 
+func constNames(stmts []ast.Statement) map[string]bool {
+	names := map[string]bool{}
+	for _, stmt := range stmts {
+		if ls, ok := stmt.(*ast.LetStatement); ok && ls.Constant() {
+			names[ls.Name.Value] = true
+		}
+	}
+	return names
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR_OBJ
@@ -249,50 +370,349 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	switch fn := fn.(type) {
-	case *object.Function:
-		newEnvironment := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, newEnvironment)
-		return unwrapReturnValue(evaluated)
+// frame describes the call being made, it is recorded on any error that
+// escapes the function body so tracebacks can show where it came from
+//
+// calls in tail position come back as an *object.TailCall and are run by the
+// loop here (a trampoline). The frames replaced by tail calls are remembered,
+// up to maxTailFrames of the latest ones, so tracebacks still show them
+func applyFunction(fn object.Object, args []object.Object, frame object.Frame) object.Object {
+	var tailFrames []object.Frame
+
+	for {
+		switch function := fn.(type) {
+		case *object.Function:
+			if function.Name != "" {
+				frame.Function = function.Name
+			}
 
-	case *object.BuiltIn:
-		return fn.Fn(args...)
+			required := len(function.Parameters) - len(function.Defaults)
+			err := checkArity(required, len(function.Parameters), function.Rest != nil, len(args))
+			if err != nil {
+				return callError(err, frame, tailFrames)
+			}
 
-	default:
-		return newError("not a function: %s", fn.Type())
+			if !function.Env.EnterCall(maxCallDepth) {
+				return callError(newError("stack overflow"), frame, tailFrames)
+			}
+
+			newEnvironment, err := extendFunctionEnv(function, args)
+			if err != nil {
+				function.Env.LeaveCall()
+				addFrame(err, frame)
+				return appendTailFrames(err, tailFrames)
+			}
+
+			// the body shares the scope of the parameters
+			evaluated := evalBlockStatement(function.Body, newEnvironment)
+			function.Env.LeaveCall()
+			if err := checkLoopControl(evaluated); err != nil {
+				addFrame(err, frame)
+				return appendTailFrames(err, tailFrames)
+			}
+			if err, ok := evaluated.(*object.Error); ok {
+				addFrame(err, frame)
+				return appendTailFrames(err, tailFrames)
+			}
+
+			result := unwrapReturnValue(evaluated)
+			if call, ok := result.(*object.TailCall); ok {
+				if len(tailFrames) == maxTailFrames {
+					tailFrames = append(tailFrames[:0], tailFrames[1:]...)
+				}
+				tailFrames = append(tailFrames, frame)
+				fn, args, frame = call.Function, call.Arguments, call.Frame
+				continue
+			}
+			// an empty body or one ending in a declaration has no value
+			if result == nil {
+				return NULL
+			}
+			return result
+
+		case *object.BuiltIn:
+			result := function.Fn(args...)
+			if err, ok := result.(*object.Error); ok {
+				return callError(err, frame, tailFrames)
+			}
+			return result
+
+		case *object.StructType:
+			result := newRecord(function, args)
+			if err, ok := result.(*object.Error); ok {
+				return callError(err, frame, tailFrames)
+			}
+			return result
+
+		case object.Callable:
+			result := function.Call(frame, args...)
+			if err, ok := result.(*object.Error); ok {
+				return callError(err, frame, tailFrames)
+			}
+			return result
+
+		default:
+			return callError(newError("not a function: %s", fn.Type()), frame, tailFrames)
+		}
 	}
 }
 
+// errors raised by the call itself rather than by the function body point
+// at the called function, like the vm reports them
+func callError(err *object.Error, frame object.Frame, tailFrames []object.Frame) *object.Error {
+	if !err.Pos.IsValid() {
+		err.Pos = frame.Pos
+	}
+	return appendTailFrames(err, tailFrames)
+}
+
+func appendTailFrames(err *object.Error, tailFrames []object.Frame) *object.Error {
+	for i := len(tailFrames) - 1; i >= 0; i-- {
+		addFrame(err, tailFrames[i])
+	}
+	return err
+}
+
+// frames past maxTraceback are dropped
+func addFrame(err *object.Error, frame object.Frame) {
+	if len(err.Stack) < maxTraceback {
+		err.Stack = append(err.Stack, frame)
+	}
+}
+
+func callFrame(node *ast.CallExpression) object.Frame {
+	name := node.Function.String()
+
+	switch fn := node.Function.(type) {
+	case *ast.Identifier:
+		name = fn.Value
+	case *ast.FunctionLiteral:
+		name = "<anonymous fn>"
+	}
+
+	return object.Frame{Function: name, Pos: node.Function.Pos()}
+}
+
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
+func evalSlice(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	var low, high object.Object
+	if node.Low != nil {
+		low = Eval(node.Low, env)
+		if isError(low) {
+			return low
+		}
+	}
+	if node.High != nil {
+		high = Eval(node.High, env)
+		if isError(high) {
+			return high
+		}
+	}
+
+	return evalSliceExpression(left, low, high)
+}
+
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-
-	if idx < 0 || idx > max {
-		return newError("Index %d is null", idx)
+	idx, ok := elementIndex(index.(*object.Integer).Value, len(arrayObject.Elements))
+	if !ok {
+		return newError("Index %d is null", index.(*object.Integer).Value)
 	}
 
 	return arrayObject.Elements[idx]
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// strings are indexed by rune, the result is a one rune string
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx, ok := elementIndex(index.(*object.Integer).Value, len(runes))
+	if !ok {
+		return newError("Index %d is null", index.(*object.Integer).Value)
+	}
+
+	return &object.String{Value: string(runes[idx])}
+}
+
+// negative indices count from the end, ok is false when idx is out of range
+func elementIndex(idx int64, length int) (int, bool) {
+	if idx < 0 {
+		idx += int64(length)
+	}
+
+	if idx < 0 || idx >= int64(length) {
+		return 0, false
+	}
+
+	return int(idx), true
+}
+
+// left[low:high] on arrays and strings, low and high are nil when left out.
+// Like indices they may be negative, and they are clamped to the length so
+// slicing never fails on a bound
+func evalSliceExpression(left, low, high object.Object) object.Object {
+	var length int
+	switch left := left.(type) {
+	case *object.Array:
+		length = len(left.Elements)
+	case *object.String:
+		length = utf8.RuneCountInString(left.Value)
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	start, err := sliceBound(low, 0, length)
+	if err != nil {
+		return err
+	}
+	end, err := sliceBound(high, length, length)
+	if err != nil {
+		return err
+	}
+	if end < start {
+		end = start
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := make([]object.Object, end-start)
+		copy(elements, left.Elements[start:end])
+		return &object.Array{Elements: elements}
+	default:
+		runes := []rune(left.(*object.String).Value)
+		return &object.String{Value: string(runes[start:end])}
+	}
+}
+
+func sliceBound(bound object.Object, fallback int, length int) (int, *object.Error) {
+	if bound == nil || bound == NULL {
+		return fallback, nil
+	}
+
+	integer, ok := bound.(*object.Integer)
+	if !ok {
+		return 0, newError("slice index must be INTEGER, got %s", bound.Type())
+	}
+
+	idx := integer.Value
+	if idx < 0 {
+		idx += int64(length)
+	}
+
+	switch {
+	case idx < 0:
+		return 0, nil
+	case idx > int64(length):
+		return length, nil
+	default:
+		return int(idx), nil
+	}
+}
+
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
+
+// every key and value is evaluated in source order before the hash is
+// built, like the vm does, so a repeated key keeps its last value
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	keysAndValues := make([]object.Object, 0, len(node.Keys)*2)
+
+	for _, keyNode := range node.Keys {
+		key := Eval(keyNode, env)
+		if isError(key) {
+			return key
+		}
+
+		value := Eval(node.Pairs[keyNode], env)
+		if isError(value) {
+			return value
+		}
+
+		keysAndValues = append(keysAndValues, key, value)
+	}
+
+	return HashOperation(keysAndValues)
+}
+
+// the arguments must already have passed checkArity. Defaults are evaluated
+// on every call in the new environment, so they can use the parameters
+// before them
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
 	env := object.NewEnclosedEnvironment(fn.Env)
+	required := len(fn.Parameters) - len(fn.Defaults)
+
+	// the rest parameter is bound first, a default may use it and the
+	// parameters before its own
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
 
-	for id, param := range fn.Parameters {
-		env.Set(param.Value, args[id])
+	for _, param := range fn.Parameters {
+		env.Hoist(param.Value)
 	}
 
-	return env
+	for i, param := range fn.Parameters {
+		if i < len(args) {
+			env.Set(param.Value, args[i])
+			continue
+		}
+
+		val := Eval(fn.Defaults[i-required], env)
+		if err, ok := val.(*object.Error); ok {
+			return nil, err
+		}
+		env.Set(param.Value, val)
+	}
+
+	return env, nil
+}
+
+// a function takes from required up to total arguments, or any number past
+// required when it has a rest parameter
+func checkArity(required, total int, variadic bool, got int) *object.Error {
+	switch {
+	case got >= required && (got <= total || variadic):
+		return nil
+	case variadic:
+		return newError("wrong number of arguments: want at least %d, got=%d", required, got)
+	case required == total:
+		return newError("wrong number of arguments: want=%d, got=%d", total, got)
+	default:
+		return newError("wrong number of arguments: want=%d to %d, got=%d", required, total, got)
+	}
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -319,12 +739,17 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 func evalBlockStatement(node *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
+	hoistNames(node.Statements, env)
+	if err := hoistFunctions(node.Statements, env); err != nil {
+		return err
+	}
+
 	for _, stmt := range node.Statements {
 		result = Eval(stmt, env)
 
 		if result != nil {
-			resultType := result.Type()
-			if resultType == object.RETURN_VAL_OBJ || resultType == object.ERROR_OBJ {
+			switch result.Type() {
+			case object.RETURN_VAL_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
 				return result
 			}
 		}
@@ -336,9 +761,18 @@ func evalBlockStatement(node *ast.BlockStatement, env *object.Environment) objec
 func evalProgram(node *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
+	hoistNames(node.Statements, env)
+	if err := hoistFunctions(node.Statements, env); err != nil {
+		return err
+	}
+
 	for _, stmt := range node.Statements {
 		result = Eval(stmt, env)
 
+		if err := checkLoopControl(result); err != nil {
+			return err
+		}
+
 		switch result := result.(type) {
 		case *object.ReturnValue:
 			return result.Value
@@ -352,9 +786,16 @@ func evalProgram(node *ast.Program, env *object.Environment) object.Object {
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
+		if val == nil {
+			return usedBeforeDeclaration(node.Value)
+		}
 		return val
 	}
 
+	if builtin, ok := env.Builtin(node.Value); ok {
+		return builtin
+	}
+
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
@@ -362,6 +803,150 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError("identifier not found: " + node.Value)
 }
 
+func usedBeforeDeclaration(name string) *object.Error {
+	return newError("identifier used before declaration: " + name)
+}
+
+// var and const declare name in the current scope, a constant of this scope
+// can not be declared again
+func evalLetStatement(node *ast.LetStatement, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if !env.Define(node.Name.Value, val, node.Constant()) {
+		return redeclaredConstant(node.Name.Value)
+	}
+
+	return nil
+}
+
+func redeclaredConstant(name string) *object.Error {
+	return newError("cannot redeclare constant " + name)
+}
+
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.IndexExpression:
+		return evalIndexAssignExpression(node, target, env)
+	case *ast.MemberExpression:
+		return evalMemberAssignExpression(node, target, env)
+	}
+
+	name := node.Target.(*ast.Identifier).Value
+
+	// a compound operator reads the target before the value is evaluated
+	current, ok := env.Get(name)
+	if node.Operator != "=" && ok && current == nil {
+		return usedBeforeDeclaration(name)
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if ok && current == nil {
+		return usedBeforeDeclaration(name)
+	}
+
+	if node.Operator != "=" {
+		if !ok {
+			return newError("identifier not found: " + name)
+		}
+
+		val = evalCompoundAssign(node.Operator, current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	if env.IsConst(name) {
+		return newError("cannot assign to constant " + name)
+	}
+
+	if _, ok := env.Assign(name, val); !ok {
+		return newError("cannot assign to undeclared identifier: " + name)
+	}
+
+	return val
+}
+
+// arr[i] = v and hash[k] = v update the container in place, the container
+// and index are evaluated before the value, and so is the current element
+// for compound operators
+func evalIndexAssignExpression(node *ast.AssignExpression, target *ast.IndexExpression, env *object.Environment) object.Object {
+	left := Eval(target.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	index := Eval(target.Index, env)
+	if isError(index) {
+		return index
+	}
+
+	var current object.Object
+	if node.Operator != "=" {
+		current = evalIndexExpression(left, index)
+		if isError(current) {
+			return current
+		}
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if node.Operator != "=" {
+		val = evalCompoundAssign(node.Operator, current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	return evalSetIndex(left, index, val)
+}
+
+// "+=" becomes "+" and so on
+func evalCompoundAssign(operator string, current, val object.Object) object.Object {
+	op := strings.TrimSuffix(operator, "=")
+	return evalInfixExpression(op, val, current)
+}
+
+func evalSetIndex(left, index, val object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		integer, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+
+		idx, ok := elementIndex(integer.Value, len(left.Elements))
+		if !ok {
+			return newError("index %d out of range for array of length %d",
+				integer.Value, len(left.Elements))
+		}
+
+		left.Elements[idx] = val
+		return val
+
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+
+		left.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: val}
+		return val
+
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	evaluation := Eval(ie.Condition, env)
 	if isError(evaluation) {
@@ -389,12 +974,19 @@ func evalWhileExpression(we *ast.WhileExpression, env *object.Environment) objec
 		return newError("expected type BOOL got: %s", condition.Type())
 	}
 
+	label := loopLabel(we.Label)
+
 	for isTruthy(condition) {
 		blockstmt := Eval(we.Consequence, env)
 		if isError(blockstmt) {
 			return blockstmt
 		}
 
+		exit, result := handleLoopControl(blockstmt, label)
+		if exit {
+			return result
+		}
+
 		condition = Eval(we.Condition, env)
 		if isError(condition) {
 			return condition
@@ -409,7 +1001,12 @@ func evalWhileExpression(we *ast.WhileExpression, env *object.Environment) objec
 	return nil
 }
 
+// the declaration gets a scope of its own that every iteration copies, so a
+// closure made in the body sees the loop variables of its own iteration
 func evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
+	env = object.NewEnclosedEnvironment(env)
+	hoistNames([]ast.Statement{fe.Declaration}, env)
+
 	decl := Eval(fe.Declaration, env)
 	if isError(decl) {
 		return decl
@@ -424,6 +1021,8 @@ func evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Ob
 		return newError("expected type BOOL got: %s", condition.Type())
 	}
 
+	label := loopLabel(fe.Label)
+
 	for isTruthy(condition) {
 
 		blockstmt := Eval(fe.Consequence, env)
@@ -431,6 +1030,12 @@ func evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Ob
 			return blockstmt
 		}
 
+		exit, result := handleLoopControl(blockstmt, label)
+		if exit {
+			return result
+		}
+
+		env = env.Copy()
 		incr := Eval(fe.Increment, env)
 		if isError(incr) {
 			return incr
@@ -449,6 +1054,61 @@ func evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Ob
 	return nil
 }
 
+func loopLabel(label *ast.Identifier) string {
+	if label == nil {
+		return ""
+	}
+	return label.Value
+}
+
+// decides what a loop does with the result of its body, exit reports
+// whether the loop should stop and result is what the loop then evaluates to.
+// break/continue aimed at another label are handed back so an enclosing loop
+// can deal with them
+func handleLoopControl(body object.Object, label string) (exit bool, result object.Object) {
+	switch body := body.(type) {
+	case *object.ReturnValue:
+		return true, body
+	case *object.Break:
+		if body.Label == "" || body.Label == label {
+			return true, nil
+		}
+		return true, body
+	case *object.Continue:
+		if body.Label == "" || body.Label == label {
+			return false, nil
+		}
+		return true, body
+	default:
+		return false, nil
+	}
+}
+
+// break/continue that escaped every loop, either used outside a loop or
+// pointing at a label that does not exist
+func checkLoopControl(obj object.Object) *object.Error {
+	var err *object.Error
+
+	switch obj := obj.(type) {
+	case *object.Break:
+		if obj.Label != "" {
+			err = newError("break label not found: %s", obj.Label)
+		} else {
+			err = newError("break outside of loop")
+		}
+		err.Pos = obj.Pos
+	case *object.Continue:
+		if obj.Label != "" {
+			err = newError("continue label not found: %s", obj.Label)
+		} else {
+			err = newError("continue outside of loop")
+		}
+		err.Pos = obj.Pos
+	}
+
+	return err
+}
+
 func isTruthy(eval object.Object) bool {
 	switch eval {
 	case TRUE:
@@ -464,6 +1124,9 @@ func isTruthy(eval object.Object) bool {
 
 func evalInfixExpression(op string, right object.Object, left object.Object) object.Object {
 	switch {
+	case isNumber(left) && isNumber(right) &&
+		(left.Type() == object.FLOAT_OBJ || right.Type() == object.FLOAT_OBJ):
+		return evalInfixFloatExpression(op, toFloat(right), toFloat(left))
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), op, right.Type())
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
@@ -471,35 +1134,145 @@ func evalInfixExpression(op string, right object.Object, left object.Object) obj
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalInfixIntegerExpression(op, right, left)
 	case op == "==":
-		return nativeToObjectBool(right == left)
+		return nativeToObjectBool(objectsEqual(left, right))
 	case op == "!=":
-		return nativeToObjectBool(right != left)
-	case op == "&&":
-		if left == TRUE && right == TRUE {
-			return TRUE
-		} else {
-			return FALSE
+		return nativeToObjectBool(!objectsEqual(left, right))
+	default:
+		return newError("unknown infix operator: %s%s%s", left.Type(), op, right.Type())
+	}
+}
+
+// structural equality, arrays and hashes are compared element by element
+// while everything else without a value falls back to identity
+func objectsEqual(left, right object.Object) bool {
+	return equalObjects(left, right, nil)
+}
+
+// a pair of values being compared further up, reaching it again means the
+// values contain themselves and the comparison so far found no difference
+type comparison struct {
+	left, right object.Object
+}
+
+func equalObjects(left, right object.Object, visited map[comparison]bool) bool {
+	// ints are compared exactly, only a mix with a float is promoted
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			return l.Value == r.Value
 		}
-	case op == "||":
-		if left == TRUE || right == TRUE {
-			return TRUE
-		} else {
-			return FALSE
+	}
+	if isNumber(left) && isNumber(right) {
+		return toFloat(left) == toFloat(right)
+	}
+
+	if left == right {
+		return true
+	}
+
+	if left.Type() != right.Type() {
+		return false
+	}
+
+	switch left.(type) {
+	case *object.Array, *object.Record, *object.Hash:
+		pair := comparison{left, right}
+		if visited[pair] {
+			return true
 		}
+		if visited == nil {
+			visited = make(map[comparison]bool)
+		}
+		visited[pair] = true
+	}
+
+	switch left := left.(type) {
+	case *object.String:
+		return left.Value == right.(*object.String).Value
+	case *object.Array:
+		other := right.(*object.Array)
+		if len(left.Elements) != len(other.Elements) {
+			return false
+		}
+		for i, el := range left.Elements {
+			if !equalObjects(el, other.Elements[i], visited) {
+				return false
+			}
+		}
+		return true
+	case *object.Record:
+		other := right.(*object.Record)
+		if left.Struct != other.Struct {
+			return false
+		}
+		for i, val := range left.Values {
+			if !equalObjects(val, other.Values[i], visited) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		other := right.(*object.Hash)
+		if len(left.Pairs) != len(other.Pairs) {
+			return false
+		}
+		for key, pair := range left.Pairs {
+			otherPair, ok := other.Pairs[key]
+			if !ok || !equalObjects(pair.Value, otherPair.Value, visited) {
+				return false
+			}
+		}
+		return true
 	default:
-		return newError("unknown infix operator: %s%s%s", left.Type(), op, right.Type())
+		return left == right
 	}
 }
 
+// && and || only evaluate the right operand when the left one does not
+// already decide the result
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+
+	return nativeToObjectBool(isTruthy(right))
+}
+
 func evalInfixStringExpression(op string, right object.Object, left object.Object) object.Object {
 	left_val := left.(*object.String).Value
 	right_val := right.(*object.String).Value
 
+	// strings are ordered lexicographically by their bytes
 	switch op {
 	case "+":
 		return &object.String{Value: fmt.Sprintf("%s%s", left_val, right_val)}
+	case "==":
+		return nativeToObjectBool(left_val == right_val)
+	case "!=":
+		return nativeToObjectBool(left_val != right_val)
+	case "<":
+		return nativeToObjectBool(left_val < right_val)
+	case ">":
+		return nativeToObjectBool(left_val > right_val)
+	case "<=":
+		return nativeToObjectBool(left_val <= right_val)
+	case ">=":
+		return nativeToObjectBool(left_val >= right_val)
 	default:
-		return NULL
+		return newError("unknown infix operator: %s%s%s", left.Type(), op, right.Type())
 	}
 }
 
@@ -509,6 +1282,9 @@ func evalInfixIntegerExpression(op string, right object.Object, left object.Obje
 
 	switch op {
 	case "%":
+		if right_val == 0 {
+			return newError("division by zero: %d %% %d", left_val, right_val)
+		}
 		return &object.Integer{Value: left_val % right_val}
 	case "+":
 		return &object.Integer{Value: left_val + right_val}
@@ -517,11 +1293,18 @@ func evalInfixIntegerExpression(op string, right object.Object, left object.Obje
 	case "*":
 		return &object.Integer{Value: left_val * right_val}
 	case "/":
+		if right_val == 0 {
+			return newError("division by zero: %d / %d", left_val, right_val)
+		}
 		return &object.Integer{Value: left_val / right_val}
 	case ">":
 		return nativeToObjectBool(left_val > right_val)
 	case "<":
 		return nativeToObjectBool(left_val < right_val)
+	case ">=":
+		return nativeToObjectBool(left_val >= right_val)
+	case "<=":
+		return nativeToObjectBool(left_val <= right_val)
 	case "==":
 		return nativeToObjectBool(left_val == right_val)
 	case "!=":
@@ -531,6 +1314,51 @@ func evalInfixIntegerExpression(op string, right object.Object, left object.Obje
 	}
 }
 
+func evalInfixFloatExpression(op string, right_val float64, left_val float64) object.Object {
+	switch op {
+	case "%":
+		return &object.Float{Value: math.Mod(left_val, right_val)}
+	case "+":
+		return &object.Float{Value: left_val + right_val}
+	case "-":
+		return &object.Float{Value: left_val - right_val}
+	case "*":
+		return &object.Float{Value: left_val * right_val}
+	case "/":
+		return &object.Float{Value: left_val / right_val}
+	case ">":
+		return nativeToObjectBool(left_val > right_val)
+	case "<":
+		return nativeToObjectBool(left_val < right_val)
+	case ">=":
+		return nativeToObjectBool(left_val >= right_val)
+	case "<=":
+		return nativeToObjectBool(left_val <= right_val)
+	case "==":
+		return nativeToObjectBool(left_val == right_val)
+	case "!=":
+		return nativeToObjectBool(left_val != right_val)
+	default:
+		return newError("unknown infix operator: %s%s%s", object.FLOAT_OBJ, op, object.FLOAT_OBJ)
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// promotes INTEGER to FLOAT, callers must check isNumber first
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Float:
+		return obj.Value
+	case *object.Integer:
+		return float64(obj.Value)
+	default:
+		return 0
+	}
+}
+
 func evalPrefixExpression(op string, right object.Object) object.Object {
 	switch op {
 	case "!":
@@ -543,12 +1371,14 @@ func evalPrefixExpression(op string, right object.Object) object.Object {
 }
 
 func evalMinusOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("not of type INT: %s", right.Type())
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError("not of type INT or FLOAT: %s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalBangOperatorExpression(right object.Object) object.Object {
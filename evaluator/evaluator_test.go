@@ -4,6 +4,9 @@ import (
 	"go_interpreter/lexer"
 	"go_interpreter/object"
 	"go_interpreter/parser"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -26,3 +29,1029 @@ func TestStringLiteral(t *testing.T) {
 		t.Errorf("String has wrong value. got=%q", str.Value)
 	}
 }
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `var two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`var key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"name": "tom", 1: true}[1]`, true},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+		{`var n = 0; var next = fn() { n = n * 10 + 1; n }; var h = {"b": next(), "a": next(), "c": next()}; h["c"]`, 111},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			if evaluated != nativeToObjectBool(expected) {
+				t.Errorf("object is not %t. got=%T (%+v)", expected, evaluated, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashKeyErrors(t *testing.T) {
+	input := `{"name": "tom"}[fn(x) { x }]`
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "unusable as hash key: FUNCTION_OBJ" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestFloatExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"2.5", 2.5},
+		{"-2.5", -2.5},
+		{"3.0 / 2", 1.5},
+		{"3 / 2.0", 1.5},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"10 - 2.5", 7.5},
+		{"5.5 % 2", 1.5},
+		{"var total = 7; var count = 2.0; total / count", 3.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestMixedNumberComparisons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 < 1.5", true},
+		{"2.5 > 3", false},
+		{"2 == 2.0", true},
+		{"2 != 2.0", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated != nativeToObjectBool(tt.expected) {
+			t.Errorf("%s: object is not %t. got=%T (%+v)", tt.input, tt.expected, evaluated, evaluated)
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1.5, "1.5"},
+		{2, "2.0"},
+		{-0.25, "-0.25"},
+	}
+
+	for _, tt := range tests {
+		f := &object.Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect. got=%q, want=%q", f.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestIntegerDivisionByZero(t *testing.T) {
+	evaluated := testEval("1 / 0")
+	if _, ok := evaluated.(*object.Error); !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"var x = 1; x = 5; x", 5},
+		{"var x = 1; x += 2; x", 3},
+		{"var x = 5; x -= 2; x", 3},
+		{"var x = 3; x *= 4; x", 12},
+		{"var x = 8; x /= 2; x", 4},
+		{"var x = 1; var y = 1; x = y = 7; x + y", 14},
+		{"var x = 1; var f = fn() { x = 10; }; f(); x", 10},
+		{"var x = 0; var f = fn() { x += 1; }; f(); f(); x", 2},
+		{"var x = 0; while (x < 5) { x += 1; }; x", 5},
+		{"var n = 0; for (var i = 0; i < 4; i += 1) { n += i; }; n", 6},
+		{"var x = 1; x += (x = 10)", 11},
+		{"var a = [1]; a[0] += (a[0] = 10); a[0]", 11},
+		{"struct P { v } var p = P(1); p.v += (p.v = 10); p.v", 11},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestAssignUndeclaredIdentifier(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"x = 5", "cannot assign to undeclared identifier: x"},
+		{"var f = fn() { y = 1 }; f()", "cannot assign to undeclared identifier: y"},
+		{"x += 5", "identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestBreakAndContinue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"var i = 0; while (true) { i += 1; if (i == 5) { break; } }; i", 5},
+		{"var n = 0; for (var i = 0; i < 10; i += 1) { if (i == 3) { break; } n += 1; }; n", 3},
+		{"var n = 0; for (var i = 0; i < 10; i += 1) { if (i % 2 == 0) { continue; } n += i; }; n", 25},
+		{"var i = 0; var n = 0; while (i < 5) { i += 1; if (i == 2) { continue; } n += 1; }; n", 4},
+		{`var found = 0;
+		outer: for (var i = 0; i < 5; i += 1) {
+			for (var j = 0; j < 5; j += 1) {
+				if (i * j == 6) { found = i * 10 + j; break outer; }
+			}
+		};
+		found`, 23},
+		{`var n = 0;
+		outer: for (var i = 0; i < 3; i += 1) {
+			var j = 0;
+			while (true) {
+				j += 1;
+				if (j > 2) { continue outer; }
+				n += 1;
+			}
+		};
+		n`, 6},
+		{"var f = fn() { while (true) { return 7; } }; f()", 7},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLoopControlErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"break;", "break outside of loop"},
+		{"var f = fn() { continue; }; f()", "continue outside of loop"},
+		{"while (true) { break nope; }", "break label not found: nope"},
+		{"var f = fn() { break; }; while (true) { f(); }", "break outside of loop"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+
+	errObj := testEval("var f = fn() { break; }; f()").(*object.Error)
+	if len(errObj.Stack) != 1 || errObj.Stack[0].Function != "f" {
+		t.Errorf("wrong stack. got=%v", errObj.Stack)
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 3 < 4", true},
+		{"1 < 2 && 4 < 3", false},
+		{"1 > 2 || 3 < 4", true},
+		{"true || false && false", true},
+		{"(true || false) && false", false},
+		{"1 && \"a\"", true},
+		{"false || [1]", true},
+		{"var arr = [1, 2]; var i = 5; i < 2 && arr[i] == 1", false},
+		{"var arr = [1, 2]; var i = 5; i > 0 || arr[i] == 1", true},
+		{"var calls = 0; var f = fn() { calls += 1; true }; false && f(); calls == 0", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated != nativeToObjectBool(tt.expected) {
+			t.Errorf("%s: object is not %t. got=%T (%+v)", tt.input, tt.expected, evaluated, evaluated)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{"1 + true", 1, 3},
+		{"var a = 1;\nvar b = a + missing;", 2, 13},
+		{"var f = fn() {\n  -true\n};\nf()", 2, 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Pos.Line != tt.line || errObj.Pos.Column != tt.column {
+			t.Errorf("%q: wrong position. expected=%d:%d, got=%s",
+				tt.input, tt.line, tt.column, errObj.Pos)
+		}
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	input := `var inner = fn(x) {
+  x + missing
+};
+var outer = fn() { inner(1) };
+outer();`
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := []struct {
+		function string
+		line     int
+		column   int
+	}{
+		{"inner", 4, 20},
+		{"outer", 5, 1},
+	}
+
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong stack depth. want=%d, got=%d (%v)", len(expected), len(errObj.Stack), errObj.Stack)
+	}
+
+	for i, tt := range expected {
+		frame := errObj.Stack[i]
+		if frame.Function != tt.function {
+			t.Errorf("stack[%d] - wrong function. want=%q, got=%q", i, tt.function, frame.Function)
+		}
+		if frame.Pos.Line != tt.line || frame.Pos.Column != tt.column {
+			t.Errorf("stack[%d] - wrong position. want=%d:%d, got=%s", i, tt.line, tt.column, frame.Pos)
+		}
+	}
+}
+
+func TestCommentsAreIgnored(t *testing.T) {
+	input := `// counts to three
+	var n = 0; /* start */
+	while (n < 3) { n += 1; } // loop
+	/* the /* nested */ answer */ n`
+
+	testIntegerObject(t, testEval(input), 3)
+}
+
+func TestComparisonOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 <= 1", true},
+		{"1 <= 2", true},
+		{"2 <= 1", false},
+		{"1 >= 1", true},
+		{"1 >= 2", false},
+		{"1.5 >= 1", true},
+		{"2 <= 1.5", false},
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" < "b"`, true},
+		{`"b" > "a"`, true},
+		{`"apple" < "apricot"`, true},
+		{`"abc" <= "abc"`, true},
+		{`"abd" >= "abc"`, true},
+		{`"Z" < "a"`, true},
+		{"[1, 2, 3] == [1, 2, 3]", true},
+		{"[1, 2, 3] == [1, 2, 4]", false},
+		{"[1, 2] == [1, 2, 3]", false},
+		{"[1, [2, \"x\"]] == [1, [2, \"x\"]]", true},
+		{"[1, 2] != [1, 2]", false},
+		{"[1.0, 2] == [1, 2.0]", true},
+		{`{"a": [1]} == {"a": [1]}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`var a = [1]; a[0] = a; a == a`, true},
+		{`var a = [1]; a[0] = a; var b = [1]; b[0] = b; a == b`, true},
+		{`var a = [1, 1]; a[0] = a; var b = [1, 2]; b[0] = b; a == b`, false},
+		{`var h = {}; h["self"] = h; h == h`, true},
+		{"[9007199254740993] == [9007199254740992]", false},
+		{`{"n": 9007199254740993} == {"n": 9007199254740992}`, false},
+		{"[9007199254740992] == [9007199254740992.0]", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated != nativeToObjectBool(tt.expected) {
+			t.Errorf("%s: object is not %t. got=%T (%+v)", tt.input, tt.expected, evaluated, evaluated)
+		}
+	}
+}
+
+func TestUnknownStringOperator(t *testing.T) {
+	evaluated := testEval(`"a" - "b"`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "unknown infix operator: STRING_OBJ-STRING_OBJ" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func testEvalFile(t *testing.T, path string) object.Object {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read %s: %s", path, err)
+	}
+
+	p := parser.New(lexer.NewWithFile(string(source), path))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return Eval(program, object.NewEnvironment())
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImport(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lib/math.catt": `var double = fn(x) { x * 2 }; var base = 10;`,
+		"main.catt": `import "lib/math.catt" as m;
+			import "lib/math.catt";
+			m.double(m.base) + math.base`,
+	})
+
+	evaluated := testEvalFile(t, filepath.Join(dir, "main.catt"))
+	testIntegerObject(t, evaluated, 30)
+}
+
+func TestImportIsCached(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"counter.catt": `var count = [];`,
+		"other.catt":   `import "counter.catt" as c; var same = c;`,
+		"main.catt": `import "counter.catt" as a;
+			import "other.catt" as o;
+			a == o.same`,
+	})
+
+	evaluated := testEvalFile(t, filepath.Join(dir, "main.catt"))
+	if evaluated != TRUE {
+		t.Errorf("module was loaded twice. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		files    map[string]string
+		expected string
+	}{
+		{
+			map[string]string{
+				"main.catt": `import "a.catt" as a;`,
+				"a.catt":    `import "b.catt" as b;`,
+				"b.catt":    `import "a.catt" as a;`,
+			},
+			"import cycle: ",
+		},
+		{
+			map[string]string{
+				"main.catt": `import "missing.catt" as m;`,
+			},
+			"cannot import ",
+		},
+		{
+			map[string]string{
+				"main.catt": `import "lib.catt" as l; l.nothing`,
+				"lib.catt":  `var something = 1;`,
+			},
+			"module l has no member nothing",
+		},
+		{
+			map[string]string{
+				"main.catt": `const s = 1; import "lib.catt" as s; s`,
+				"lib.catt":  `var something = 1;`,
+			},
+			"cannot redeclare constant s",
+		},
+	}
+
+	for _, tt := range tests {
+		dir := writeFiles(t, tt.files)
+		evaluated := testEvalFile(t, filepath.Join(dir, "main.catt"))
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if !strings.HasPrefix(errObj.Message, tt.expected) {
+			t.Errorf("wrong error message. expected prefix %q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestTailCallsRunInConstantStack(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`var count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } };
+		count(300000, 0)`, 300000},
+		{`var count = fn(n, acc) { if (n == 0) { return acc; } return count(n - 1, acc + 1); };
+		count(300000, 0)`, 300000},
+		{`var isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+		var isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+		if (isEven(300000)) { 1 } else { 0 }`, 1},
+		{`var find = fn(n) { while (true) { if (n > 300000) { return n; } return find(n + 1); } };
+		find(0)`, 300001},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestTailCallFramesInTraceback(t *testing.T) {
+	input := `var fail = fn() { [1][5] };
+var loop = fn(n) { if (n == 0) { fail() } else { loop(n - 1) } };
+loop(3)`
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := []string{"fail", "loop", "loop", "loop", "loop"}
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong stack depth. want=%d, got=%d (%v)", len(expected), len(errObj.Stack), errObj.Stack)
+	}
+	for i, name := range expected {
+		if errObj.Stack[i].Function != name {
+			t.Errorf("stack[%d] - wrong function. want=%q, got=%q", i, name, errObj.Stack[i].Function)
+		}
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`var f = fn(n) { if (n == 0) { return 0; } 1 + f(n - 1) }; f(100000000)`, "ERROR: stack overflow"},
+		{`var f = fn(n) { if (n == 0) { return 0; } 1 + f(n - 1) }; try { f(100000000) } catch (e) { f(10) }`, "10"},
+		{`var f = fn(n) { if (n == 0) { return 0; } 1 + f(n - 1) }; f(10000)`, "10000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestDeepTracebackIsTruncated(t *testing.T) {
+	input := `var f = fn(n) { if (n == 0) { [1][5] } else { 1 + f(n - 1) } }; f(200)`
+
+	errObj, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+
+	if len(errObj.Stack) != maxTraceback {
+		t.Errorf("wrong stack depth. want=%d, got=%d", maxTraceback, len(errObj.Stack))
+	}
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len([1, 2, 3])`, "3"},
+		{`len("four")`, "4"},
+		{`len({"a": 1})`, "1"},
+		{`var a = [1]; push(a, 2); a`, "[1]"},
+		{`push([1], 2)`, "[1, 2]"},
+		{`first([7, 8])`, "7"},
+		{`last([7, 8])`, "8"},
+		{`rest([7, 8, 9])`, "[8, 9]"},
+		{`first([])`, "null"},
+		{`map([1, 2, 3], fn(x) { x * x })`, "[1, 4, 9]"},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, "[3, 4]"},
+		{`reduce([1, 2, 3], 10, fn(acc, x) { acc + x })`, "16"},
+		{`var n = 0; each([1, 2], fn(x) { n += x; }); n`, "3"},
+		{`len(1)`, "ERROR: argument type is not supported: INTEGER"},
+		{`map(1, fn(x) { x })`, "ERROR: argument to `map` must be ARRAY, got INTEGER"},
+		{`map([1], 5)`, "ERROR: not a function: INTEGER"},
+		{`push([1])`, "ERROR: supports 2 arguments, got: 1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestCallbackErrorsPropagate(t *testing.T) {
+	input := `var n = 0;
+map([1, 2, 3], fn(x) {
+  n += 1;
+  if (x == 2) { x / 0 } else { x }
+});`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	env := object.NewEnvironment()
+	evaluated := Eval(p.ParseProgram(), env)
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	if errObj.Message != "division by zero: 2 / 0" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+
+	if len(errObj.Stack) != 1 || errObj.Stack[0].Function != "<map callback>" {
+		t.Errorf("wrong stack. got=%v", errObj.Stack)
+	}
+
+	// the callback is not called again after it failed
+	n, _ := env.Get("n")
+	testIntegerObject(t, n, 2)
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len("héllo")`, "5"},
+		{`len("猫")`, "1"},
+		{`split("a,b,c", ",")`, `[a, b, c]`},
+		{`len(split("猫cat", ""))`, "4"},
+		{`join(["a", "b"], "-")`, "a-b"},
+		{`trim("  cat \n")`, "cat"},
+		{`upper("cat")`, "CAT"},
+		{`lower("CaT")`, "cat"},
+		{`contains("catnip", "nip")`, "true"},
+		{`starts_with("catnip", "nip")`, "false"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`index_of("猫cat", "cat")`, "1"},
+		{`index_of("cat", "dog")`, "-1"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`upper(1)`, "ERROR: argument to `upper` must be STRING, got INTEGER"},
+		{`replace("a", "b")`, "ERROR: supports 3 arguments, got: 2"},
+		{`join(["a", 1], "")`, "ERROR: argument to `join` must be ARRAY of STRING, got INTEGER element"},
+		{`repeat("a", -1)`, "ERROR: negative repeat count: -1"},
+		{`repeat("ab", 5000000000000000000)`, "ERROR: repeat result too long: 2 bytes times 5000000000000000000, the limit is 134217728 bytes"},
+		{`repeat("", 5000000000000000000)`, ""},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSlicesAndNegativeIndices(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3, 4][1:3]`, "[2, 3]"},
+		{`[1, 2, 3, 4][:2]`, "[1, 2]"},
+		{`[1, 2, 3, 4][2:]`, "[3, 4]"},
+		{`[1, 2, 3, 4][-3:-1]`, "[2, 3]"},
+		{`[1, 2, 3][1:10]`, "[2, 3]"},
+		{`[1, 2, 3][2:1]`, "[]"},
+		{`[1, 2, 3][-1]`, "3"},
+		{`[1, 2, 3][-3]`, "1"},
+		{`[1, 2, 3][-4]`, "ERROR: Index -4 is null"},
+		{`var a = [1, 2]; var b = a[:]; b == a`, "true"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[-1]`, "o"},
+		{`"héllo"[1:3]`, "él"},
+		{`"猫cat"[:1]`, "猫"},
+		{`"cat"[5]`, "ERROR: Index 5 is null"},
+		{`[1, 2]["a":]`, "ERROR: slice index must be INTEGER, got STRING_OBJ"},
+		{`5[1:2]`, "ERROR: slice operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`var a = [1, 2, 3]; a[1] = 9; a`, "[1, 9, 3]"},
+		{`var a = [1, 2, 3]; a[-1] = 0; a`, "[1, 2, 0]"},
+		{`var a = [1, 2, 3]; a[0] += 10`, "11"},
+		{`var a = [1, 2]; var b = a; b[0] = 5; a`, "[5, 2]"},
+		{`var m = [[1, 2], [3, 4]]; m[1][0] = 0; m`, "[[1, 2], [0, 4]]"},
+		{`var h = {"a": 1}; h["b"] = 2; h`, "{a: 1, b: 2}"},
+		{`var h = {"n": 1}; h["n"] *= 3; h["n"]`, "3"},
+		{`var a = [1]; a[1] = 2`, "ERROR: index 1 out of range for array of length 1"},
+		{`var a = [1]; a["x"] = 2`, "ERROR: array index must be INTEGER, got STRING_OBJ"},
+		{`var h = {}; h[[1]] = 2`, "ERROR: unusable as hash key: ARRAY"},
+		{`var s = "cat"; s[0] = "b"`, "ERROR: index assignment not supported: STRING_OBJ"},
+		{`var a = [1, 2]; a[0] = a; a`, "[[...], 2]"},
+		{`var a = [1]; a[0] = a; meowln(a)`, "[[...]]"},
+		{`var h = {"n": 1}; h["self"] = h; h`, "{n: 1, self: {...}}"},
+		{`var b = [1]; [b, b]`, "[[1], [1]]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestRecords(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`struct Cat { name, age } var c = Cat("tom", 3); c`, "Cat{name: tom, age: 3}"},
+		{`struct Cat { name, age } Cat("tom", 3).name`, "tom"},
+		{`struct Cat { name, age } var c = Cat("tom", 3); c.age += 1; c.age`, "4"},
+		{`struct Cat { name, age } var c = Cat("tom", 3); c.name = "felix"; c`, "Cat{name: felix, age: 3}"},
+		{`struct P { x } [P(1), P(2)][1].x`, "2"},
+		{`struct Node { next } var n = Node(1); n.next = [n]; n`, "Node{next: [Node{...}]}"},
+		{`struct P { x } P(1) == P(1)`, "true"},
+		{`struct P { x } struct Q { x } P(1) == Q(1)`, "false"},
+		{`struct Cat { name, age } Cat`, "struct Cat { name, age }"},
+		{`struct Cat { name, age } Cat("tom")`, "ERROR: wrong number of fields for Cat: want=2, got=1"},
+		{`struct Cat { name } Cat("tom").age`, "ERROR: Cat has no field age"},
+		{`struct Cat { name } var c = Cat("tom"); c.age = 1`, "ERROR: Cat has no field age"},
+		{`var n = 1; n.x = 2`, "ERROR: member assignment not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { throw "boom" } catch (e) { e.message }`, "boom"},
+		{`try { 1 } catch (e) { 2 }`, "1"},
+		{`try { len(1) } catch (e) { e.message }`, "argument type is not supported: INTEGER"},
+		{`try { throw [1, 2] } catch (e) { e.message }`, "[1, 2]"},
+		{`try { missing } catch (e) { e }`, "Error{message: identifier not found: missing, stack: [], value: null}"},
+		{`try { throw [1, 2] } catch (e) { e.value[1] }`, "2"},
+		{`try { throw {"code": 7} } catch (e) { e.value["code"] }`, "7"},
+		{`try { throw "a" } catch (e) { e.value }`, "a"},
+		{`try { try { throw [3] } catch (e) { throw e } } catch (e) { e.value }`, "[3]"},
+		{`var f = fn() { throw "a" }; try { f() } catch (e) { e.stack }`, "[f (1:35)]"},
+		{`var log = []; try { throw "a" } catch (e) { log = push(log, 1) } finally { log = push(log, 2) }; log`, "[1, 2]"},
+		{`var f = fn() { try { return 1 } finally { return 2 } }; f()`, "2"},
+		{`var n = 0; var f = fn() { try { return 1 } finally { n = 5 } }; f() + n`, "6"},
+		{`try { throw "a" } catch (e) { throw e.message + "b" }`, "ERROR: ab"},
+		{`try { try { throw "a" } finally { 1 } } catch (e) { e.message }`, "a"},
+		{`var i = 0; while (i < 5) { try { i += 1; if (i == 3) { break } } finally { 1 } }; i`, "3"},
+		{`throw "uncaught"`, "ERROR: uncaught"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match 2 { 1 => "one", 2 => "two", _ => "many" }`, "two"},
+		{`match 9 { 1 => "one", _ => "many" }`, "many"},
+		{`match "x" { "x" => 1, _ => 2 }`, "1"},
+		{`match -1 { -1 => "minus one" }`, "minus one"},
+		{`match 2.0 { 2 => "two" }`, "two"},
+		{`match [1, 2] { [a] => a, [a, b] => a + b }`, "3"},
+		{`match [1, [2, 3]] { [_, [x, y]] => x * y }`, "6"},
+		{`match "ab" { [a, b] => 1, s => s + "!" }`, "ab!"},
+		{`match 5 { 1 => 1 }`, "null"},
+		{`var x = 1; match 5 { x => x }; x`, "1"},
+		{`match 5 { n => { var m = n * 2; m } }; m`, "ERROR: identifier not found: m"},
+		{`var hits = 0; match 1 { 1 => { hits += 1 } }; hits`, "1"},
+		{`var f = fn(n) { match n { 0 => "done", _ => f(n - 1) } }; f(10000)`, "done"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`var f = fn(a, b) { a + b }; f(1)`, "ERROR: wrong number of arguments: want=2, got=1"},
+		{`var f = fn(a, b) { a + b }; f(1, 2, 3)`, "ERROR: wrong number of arguments: want=2, got=3"},
+		{`var f = fn(a, b = 10) { a + b }; f(1)`, "11"},
+		{`var f = fn(a, b = 10) { a + b }; f(1, 2)`, "3"},
+		{`var f = fn(a, b = 10) { a + b }; f()`, "ERROR: wrong number of arguments: want=1 to 2, got=0"},
+		{`var f = fn(a, b = a * 2) { b }; f(4)`, "8"},
+		{`var n = 0; var f = fn(a = n) { a }; n = 5; f()`, "5"},
+		{`var f = fn(first, ...rest) { rest }; f(1, 2, 3)`, "[2, 3]"},
+		{`var f = fn(first, ...rest) { rest }; f(1)`, "[]"},
+		{`var f = fn(first, ...rest) { rest }; f()`, "ERROR: wrong number of arguments: want at least 1, got=0"},
+		{`var f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1, 3, 5, 7)`, "[1, 3, [5, 7]]"},
+		{`fn(a, b = 2, ...rest) { a }`, "fn(a, b = 2, ...rest) {\na\n}"},
+		{`map([1], fn(x, y) { x })`, "ERROR: wrong number of arguments: want=2, got=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestConstBindings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`const x = 5; x * 2`, "10"},
+		{`const x = 5; x = 6`, "ERROR: cannot assign to constant x"},
+		{`const x = 5; x += 1; x`, "ERROR: cannot assign to constant x"},
+		{`const x = 5; var x = 6`, "ERROR: cannot redeclare constant x"},
+		{`const x = 5; const x = 6`, "ERROR: cannot redeclare constant x"},
+		{`var x = 5; const x = 6; x`, "6"},
+		{`const x = 5; var f = fn() { x = 1 }; f()`, "ERROR: cannot assign to constant x"},
+		{`const x = 5; var f = fn() { var x = 1; x += 1; x }; f()`, "2"},
+		{`const a = [1]; a[0] = 2; a`, "[2]"},
+		{`const x = 5; try { x = 1 } catch (e) { e.message }`, "cannot assign to constant x"},
+		{`const C = 1; struct C { a }; meowln(C(1))`, "ERROR: cannot redeclare constant C"},
+		{`const k = 1; fn k() { 2 }; k`, "ERROR: cannot redeclare constant k"},
+		{`fn k() { 2 } const k = 1; k`, "ERROR: cannot redeclare constant k"},
+		{`const k = 1; if (true) { fn k() { 2 } k() }`, "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+// like the repl, every line runs in the same environment
+func TestConstAcrossPrograms(t *testing.T) {
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New(`const f = 1;`)).ParseProgram(), env)
+
+	evaluated := Eval(parser.New(lexer.New(`fn f() { 2 }`)).ParseProgram(), env)
+	if evaluated == nil || evaluated.Inspect() != "ERROR: cannot redeclare constant f" {
+		t.Errorf("fn statement replaced the constant. got=%v", evaluated)
+	}
+
+	if val, _ := env.Get("f"); val.Inspect() != "1" {
+		t.Errorf("wrong value for f. got=%s", val.Inspect())
+	}
+}
+
+func TestBlockScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`if (true) { var x = 1; }; x`, "ERROR: identifier not found: x"},
+		{`var x = 1; if (true) { var x = 2; }; x`, "1"},
+		{`var x = 1; if (true) { x = 2; }; x`, "2"},
+		{`for (var i = 0; i < 3; i += 1) { }; i`, "ERROR: identifier not found: i"},
+		{`var i = 0; while (i < 3) { var seen = i; i += 1; }; seen`, "ERROR: identifier not found: seen"},
+		{`var i = 0; while (i < 3) { const step = 1; i += step; }; i`, "3"},
+		{`try { throw "a" } catch (e) { 1 }; e`, "ERROR: identifier not found: e"},
+		{`var f = fn(x) { if (x) { var y = 1; } y }; f(true)`, "ERROR: identifier not found: y"},
+		{`var fs = []; for (var i = 0; i < 3; i += 1) { fs = push(fs, fn() { i }); }; map(fs, fn(f) { f() })`, "[0, 1, 2]"},
+		{`var fs = []; for (var i = 0; i < 3; i += 1) { fs = push(fs, fn() { i += 10; i }); }; [fs[0](), fs[0](), fs[1]()]`, "[10, 20, 11]"},
+		{`var fs = []; var i = 0; while (i < 3) { var v = i * 2; fs = push(fs, fn() { v }); i += 1; }; map(fs, fn(f) { f() })`, "[0, 2, 4]"},
+		{`var fs = []; for (var i = 0; i < 4; i += 1) { if (i == 1) { continue; } fs = push(fs, fn() { i }); }; map(fs, fn(f) { f() })`, "[0, 2, 3]"},
+		{`var x = 1; if (true) { x = 2; var x = 3; }; x`, "ERROR: identifier used before declaration: x"},
+		{`var x = 1; if (true) { var y = x; var x = 3; y }`, "ERROR: identifier used before declaration: x"},
+		{`var x = 1; if (true) { var g = fn() { x }; var r = g(); var x = 3; r }`, "ERROR: identifier used before declaration: x"},
+		{`var x = 1; var f = fn() { x = 2; var x = 3; x }; f()`, "ERROR: identifier used before declaration: x"},
+		{`var f = fn() { var r = x; var x = 3; r }; var x = 1; f()`, "ERROR: identifier used before declaration: x"},
+		{`var f = fn() { g() }; var g = fn() { 7 }; f()`, "7"},
+		{`var f = fn() { g() }; f(); var g = fn() { 7 }`, "ERROR: identifier used before declaration: g"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFunctionStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`var r = double(4); fn double(x) { x * 2 } r`, "8"},
+		{`fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } } fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } } isOdd(7)`, "true"},
+		{`var f = fn() { var r = g(); fn g() { 5 } r }; f()`, "5"},
+		{`fn add(a, b = 1) { a + b } add`, "fn add(a, b = 1) {\n(a + b)\n}"},
+		{`fn one() { 1 } var two = fn() { 2 }; two`, "fn() {\n2\n}"},
+		{`var r = if (true) { helper() }; fn helper() { "later" } r`, "later"},
+		{`var f = fn() {}; meowln(f());`, "ERROR: argument type is not supported: NULL"},
+		{`var f = fn() { var x = 1; }; [f(), len([f()])]`, "[null, 1]"},
+		{`var y = if (true) { var x = 1; }; [y]`, "[null]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFunctionStatementNameInTraceback(t *testing.T) {
+	input := `fn boom(x) { x / 0 }
+var alias = boom;
+alias(1);`
+
+	err, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("expected an error")
+	}
+
+	if len(err.Stack) != 1 || err.Stack[0].Function != "boom" {
+		t.Errorf("wrong stack. got=%v", err.Stack)
+	}
+}
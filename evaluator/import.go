@@ -0,0 +1,69 @@
+package evaluator
+
+import (
+	"go_interpreter/ast"
+	"go_interpreter/lexer"
+	"go_interpreter/object"
+	"go_interpreter/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	module := importModule(node.Path.Value, node.Alias.Value, node.Pos().File, env)
+	if isError(module) {
+		return module
+	}
+
+	if !env.Define(node.Alias.Value, module, false) {
+		return redeclaredConstant(node.Alias.Value)
+	}
+
+	return nil
+}
+
+// loads the file at path into its own environment, paths are relative to
+// the directory of the importing file (or the working directory for the repl)
+func importModule(path string, name string, importer string, env *object.Environment) object.Object {
+	if !filepath.IsAbs(path) && importer != "" {
+		path = filepath.Join(filepath.Dir(importer), path)
+	}
+
+	resolved, err := filepath.Abs(path)
+	if err != nil {
+		return newError("cannot import %s: %s", path, err)
+	}
+
+	cache := env.Modules()
+	if module, ok := cache.Get(resolved); ok {
+		return module
+	}
+
+	if cycle := cache.Begin(resolved); cycle != nil {
+		return newError("import cycle: %s", strings.Join(cycle, " -> "))
+	}
+	defer cache.End(resolved)
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return newError("cannot import %s: %s", path, err)
+	}
+
+	p := parser.New(lexer.NewWithFile(string(source), path))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError("cannot import %s: %s", path, strings.Join(p.Errors(), "; "))
+	}
+
+	moduleEnv := object.NewModuleEnvironment(env)
+	result := Eval(program, moduleEnv)
+	if isError(result) {
+		return result
+	}
+
+	module := &object.Module{Name: name, Path: path, Env: moduleEnv}
+	cache.Set(resolved, module)
+
+	return module
+}
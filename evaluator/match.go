@@ -0,0 +1,52 @@
+package evaluator
+
+import (
+	"go_interpreter/ast"
+	"go_interpreter/object"
+)
+
+// every arm gets its own environment for the names its pattern binds, the
+// first arm that matches is evaluated and no match at all gives NULL
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	value := Eval(me.Value, env)
+	if isError(value) {
+		return value
+	}
+
+	for _, arm := range me.Arms {
+		armEnv := object.NewEnclosedEnvironment(env)
+		if matchPattern(arm.Pattern, value, armEnv) {
+			return Eval(arm.Body, armEnv)
+		}
+	}
+
+	return NULL
+}
+
+// identifiers match anything and bind it in env, _ binds nothing. Arrays
+// match arrays of the same length element by element and literals match
+// equal values
+func matchPattern(pattern ast.Expression, value object.Object, env *object.Environment) bool {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			env.Set(pattern.Value, value)
+		}
+		return true
+
+	case *ast.ArrayLiteral:
+		array, ok := value.(*object.Array)
+		if !ok || len(array.Elements) != len(pattern.Elements) {
+			return false
+		}
+		for i, el := range pattern.Elements {
+			if !matchPattern(el, array.Elements[i], env) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return objectsEqual(Eval(pattern, env), value)
+	}
+}
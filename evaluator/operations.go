@@ -0,0 +1,90 @@
+package evaluator
+
+import "go_interpreter/object"
+
+// the vm backend runs its operators through these so both backends agree on
+// results and error messages
+
+func InfixOperation(op string, left, right object.Object) object.Object {
+	return evalInfixExpression(op, right, left)
+}
+
+func PrefixOperation(op string, right object.Object) object.Object {
+	return evalPrefixExpression(op, right)
+}
+
+func IndexOperation(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// left[index] = value, returns value or an error
+func SetIndexOperation(left, index, value object.Object) object.Object {
+	return evalSetIndex(left, index, value)
+}
+
+func MemberOperation(left object.Object, name string) object.Object {
+	return memberOf(left, name)
+}
+
+func SetMemberOperation(left object.Object, name string, value object.Object) object.Object {
+	return setMember(left, name, value)
+}
+
+func NewRecord(st *object.StructType, args []object.Object) object.Object {
+	return newRecord(st, args)
+}
+
+// the value catch binds for err
+func ErrorValue(err *object.Error) object.Object {
+	return errorValue(err)
+}
+
+// the error raised by throw val
+func ThrowOperation(val object.Object) *object.Error {
+	return thrownError(val)
+}
+
+// whether value matches a literal pattern
+func MatchOperation(pattern, value object.Object) bool {
+	return objectsEqual(pattern, value)
+}
+
+func CheckArity(required, total int, variadic bool, got int) *object.Error {
+	return checkArity(required, total, variadic, got)
+}
+
+// a left out bound is passed as NULL
+func SliceOperation(left, low, high object.Object) object.Object {
+	return evalSliceExpression(left, low, high)
+}
+
+// builds a hash from alternating keys and values
+func HashOperation(keysAndValues []object.Object) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		key, value := keysAndValues[i], keysAndValues[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	return &object.Hash{Pairs: pairs}
+}
+
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+func NativeBool(input bool) object.Object {
+	return nativeToObjectBool(input)
+}
+
+func LookupBuiltin(name string) (*object.BuiltIn, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
@@ -0,0 +1,106 @@
+package evaluator
+
+import (
+	"go_interpreter/ast"
+	"go_interpreter/object"
+)
+
+func evalStructStatement(node *ast.StructStatement, env *object.Environment) object.Object {
+	fields := []string{}
+	for _, f := range node.Fields {
+		fields = append(fields, f.Value)
+	}
+
+	st := &object.StructType{Name: node.Name.Value, Fields: fields}
+	if !env.Define(node.Name.Value, st, false) {
+		return redeclaredConstant(node.Name.Value)
+	}
+
+	return nil
+}
+
+// calling a struct type builds a record, the arguments fill the fields in
+// the order they were declared
+func newRecord(st *object.StructType, args []object.Object) object.Object {
+	if len(args) != len(st.Fields) {
+		return newError("wrong number of fields for %s: want=%d, got=%d",
+			st.Name, len(st.Fields), len(args))
+	}
+
+	values := make([]object.Object, len(args))
+	copy(values, args)
+
+	return &object.Record{Struct: st, Values: values}
+}
+
+func evalMemberExpression(node *ast.MemberExpression, env *object.Environment) object.Object {
+	left := Eval(node.Object, env)
+	if isError(left) {
+		return left
+	}
+
+	return memberOf(left, node.Property.Value)
+}
+
+func memberOf(left object.Object, name string) object.Object {
+	switch left := left.(type) {
+	case *object.Module:
+		if val, ok := left.Env.GetLocal(name); ok {
+			return val
+		}
+		return newError("module %s has no member %s", left.Name, name)
+	case *object.Record:
+		idx := left.Struct.FieldIndex(name)
+		if idx < 0 {
+			return newError("%s has no field %s", left.Struct.Name, name)
+		}
+		return left.Values[idx]
+	default:
+		return newError("member access not supported: %s", left.Type())
+	}
+}
+
+// record.field = v, compound operators read the field first
+func evalMemberAssignExpression(node *ast.AssignExpression, target *ast.MemberExpression, env *object.Environment) object.Object {
+	left := Eval(target.Object, env)
+	if isError(left) {
+		return left
+	}
+
+	var current object.Object
+	if node.Operator != "=" {
+		current = memberOf(left, target.Property.Value)
+		if isError(current) {
+			return current
+		}
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if node.Operator != "=" {
+		val = evalCompoundAssign(node.Operator, current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	return setMember(left, target.Property.Value, val)
+}
+
+func setMember(left object.Object, name string, val object.Object) object.Object {
+	record, ok := left.(*object.Record)
+	if !ok {
+		return newError("member assignment not supported: %s", left.Type())
+	}
+
+	idx := record.Struct.FieldIndex(name)
+	if idx < 0 {
+		return newError("%s has no field %s", record.Struct.Name, name)
+	}
+
+	record.Values[idx] = val
+	return val
+}
@@ -0,0 +1,178 @@
+package evaluator
+
+import (
+	"go_interpreter/object"
+	"strings"
+	"unicode/utf8"
+)
+
+// the longest string repeat builds, in bytes
+const maxRepeatLength = 1 << 27
+
+func init() {
+	for name, builtin := range stringBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+var stringBuiltins = map[string]*object.BuiltIn{
+	"split": {
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArguments("split", 2, args)
+			if err != nil {
+				return err
+			}
+
+			// an empty separator splits between every rune
+			parts := strings.Split(strs[0], strs[1])
+			elements := make([]object.Object, 0, len(parts))
+			for _, part := range parts {
+				elements = append(elements, &object.String{Value: part})
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"join": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("supports 2 arguments, got: %d", len(args))
+			}
+
+			arr, err := arrayArgument("join", args[0])
+			if err != nil {
+				return err
+			}
+
+			sep, ok := args[1].(*object.String)
+			if !ok {
+				return newError("argument to `join` must be STRING, got %s", args[1].Type())
+			}
+
+			parts := make([]string, 0, len(arr.Elements))
+			for _, el := range arr.Elements {
+				str, ok := el.(*object.String)
+				if !ok {
+					return newError("argument to `join` must be ARRAY of STRING, got %s element", el.Type())
+				}
+				parts = append(parts, str.Value)
+			}
+			return &object.String{Value: strings.Join(parts, sep.Value)}
+		},
+	},
+	"trim": {
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArguments("trim", 1, args)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: strings.TrimSpace(strs[0])}
+		},
+	},
+	"upper": {
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArguments("upper", 1, args)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ToUpper(strs[0])}
+		},
+	},
+	"lower": {
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArguments("lower", 1, args)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ToLower(strs[0])}
+		},
+	},
+	"contains": {
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArguments("contains", 2, args)
+			if err != nil {
+				return err
+			}
+			return nativeToObjectBool(strings.Contains(strs[0], strs[1]))
+		},
+	},
+	"starts_with": {
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArguments("starts_with", 2, args)
+			if err != nil {
+				return err
+			}
+			return nativeToObjectBool(strings.HasPrefix(strs[0], strs[1]))
+		},
+	},
+	"replace": {
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArguments("replace", 3, args)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ReplaceAll(strs[0], strs[1], strs[2])}
+		},
+	},
+	"index_of": {
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArguments("index_of", 2, args)
+			if err != nil {
+				return err
+			}
+
+			// counted in runes like len, -1 when not found
+			idx := strings.Index(strs[0], strs[1])
+			if idx < 0 {
+				return &object.Integer{Value: -1}
+			}
+			return &object.Integer{Value: int64(utf8.RuneCountInString(strs[0][:idx]))}
+		},
+	},
+	"repeat": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("supports 2 arguments, got: %d", len(args))
+			}
+
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `repeat` must be STRING, got %s", args[0].Type())
+			}
+
+			count, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("argument to `repeat` must be INTEGER, got %s", args[1].Type())
+			}
+			if count.Value < 0 {
+				return newError("negative repeat count: %d", count.Value)
+			}
+			// divided so a huge count can not overflow the product
+			if count.Value > 0 && int64(len(str.Value)) > maxRepeatLength/count.Value {
+				return newError("repeat result too long: %d bytes times %d, the limit is %d bytes",
+					len(str.Value), count.Value, maxRepeatLength)
+			}
+
+			return &object.String{Value: strings.Repeat(str.Value, int(count.Value))}
+		},
+	},
+}
+
+// checks that args are exactly n strings and returns their values
+func stringArguments(name string, n int, args []object.Object) ([]string, *object.Error) {
+	if len(args) != n {
+		if n == 1 {
+			return nil, newError("supports 1 argument, got: %d", len(args))
+		}
+		return nil, newError("supports %d arguments, got: %d", n, len(args))
+	}
+
+	strs := make([]string, 0, n)
+	for _, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, newError("argument to `%s` must be STRING, got %s", name, arg.Type())
+		}
+		strs = append(strs, str.Value)
+	}
+	return strs, nil
+}
@@ -1,39 +1,86 @@
 package lexer
 
-import "go_interpreter/token"
+import (
+	"fmt"
+	"go_interpreter/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
-// Defining our Lexer Obj
+// called for every malformed piece of input, such as an unterminated string
+type ErrorHandler func(pos token.Position, msg string)
+
+// Defining our Lexer Obj, the input is walked rune by rune while position
+// and readPosition stay byte offsets into input
 type Lexer struct {
 	input        string
 	position     int
 	readPosition int
-	ch           byte
+	ch           rune
+
+	file   string
+	line   int
+	column int
+
+	errorHandler ErrorHandler
 }
 
 // Our Lexer Constructor
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFile(input, "")
+}
+
+// same as New, but every token position also records the file name
+func NewWithFile(input string, file string) *Lexer {
+	l := &Lexer{input: input, file: file, line: 1}
 	l.readChar()
 	return l
 }
 
-// reads the character at readPosition, sets the new position and increases the readPosition by 1
-// sets ch to after we reach the end of the input
+// the handler replaces any previous one, nil drops errors silently
+func (l *Lexer) SetErrorHandler(handler ErrorHandler) {
+	l.errorHandler = handler
+}
+
+func (l *Lexer) error(pos token.Position, msg string) {
+	if l.errorHandler != nil {
+		l.errorHandler(pos, msg)
+	}
+}
+
+// reads the rune at readPosition, sets the new position and moves readPosition past it
+// sets ch to 0 after we reach the end of the input
 func (l *Lexer) readChar() {
+	// already sitting on the end of the input
+	if l.position >= len(l.input) && l.column > 0 {
+		return
+	}
+
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
+
+	l.position = l.readPosition
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		r, width := utf8.DecodeRuneInString(l.input[l.readPosition:])
+		l.ch = r
+		l.readPosition += width
 	}
-	l.position = l.readPosition
-	l.readPosition += 1
 }
 
 // handles the "var map" for the lexer (token types defined in token.go)
 func (l *Lexer) NextToken() token.Token {
 	var tkn token.Token
 
-	l.skipWhiteSpace()
+	comments := l.skipWhiteSpace()
+
+	pos := l.currentPosition()
 
 	switch l.ch {
 	case '%':
@@ -58,6 +105,10 @@ func (l *Lexer) NextToken() token.Token {
 			ch := l.ch
 			l.readChar()
 			tkn = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			tkn = token.Token{Type: token.ARROW, Literal: string(ch) + string(l.ch)}
 		} else {
 			tkn = newToken(token.ASSIGN, l.ch)
 		}
@@ -81,18 +132,64 @@ func (l *Lexer) NextToken() token.Token {
 		tkn = newToken(token.RBRAC, l.ch)
 	case ',':
 		tkn = newToken(token.COMMA, l.ch)
+	case ':':
+		tkn = newToken(token.COLON, l.ch)
+	case '.':
+		if strings.HasPrefix(l.input[l.readPosition:], "..") {
+			l.readChar()
+			l.readChar()
+			tkn = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tkn = newToken(token.DOT, l.ch)
+		}
 	case '+':
-		tkn = newToken(token.PLUS, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tkn = token.Token{Type: token.PLUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tkn = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tkn = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tkn = token.Token{Type: token.MINUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tkn = newToken(token.MINUS, l.ch)
+		}
 	case '/':
-		tkn = newToken(token.SLASH, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tkn = token.Token{Type: token.SLASH_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tkn = newToken(token.SLASH, l.ch)
+		}
 	case '*':
-		tkn = newToken(token.ASTR, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tkn = token.Token{Type: token.ASTR_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tkn = newToken(token.ASTR, l.ch)
+		}
 	case '<':
-		tkn = newToken(token.LT, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tkn = token.Token{Type: token.LT_EQ, Literal: string(ch) + string(l.ch)}
+		} else {
+			tkn = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tkn = newToken(token.GT, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tkn = token.Token{Type: token.GT_EQ, Literal: string(ch) + string(l.ch)}
+		} else {
+			tkn = newToken(token.GT, l.ch)
+		}
 	case '[':
 		tkn = newToken(token.LBRACKET, l.ch)
 	case ']':
@@ -105,70 +202,215 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tkn.Literal = l.readIdentifier()
 			tkn.Type = token.LookUpIdent(tkn.Literal)
+			tkn.Pos = pos
+			tkn.Comments = comments
 			return tkn
 		} else if isDigit(l.ch) {
-			tkn.Literal = l.readNumber()
-			tkn.Type = token.INT
+			tkn = l.readNumber()
+			tkn.Pos = pos
+			tkn.Comments = comments
 			return tkn
 		}
 		tkn = newToken(token.NOT_ALLOWED, l.ch)
 	}
 	l.readChar()
+	tkn.Pos = pos
+	tkn.Comments = comments
 	return tkn
 }
 
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{File: l.file, Line: l.line, Column: l.column}
+}
+
+// reads a string literal starting at the opening quote and returns its
+// value with escape sequences already resolved
 func (l *Lexer) ReadString() string {
-	position := l.readPosition
+	var out strings.Builder
+	start := l.currentPosition()
+
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+
+		switch l.ch {
+		case '"':
+			return out.String()
+		case 0:
+			l.error(start, "unterminated string literal")
+			return out.String()
+		case '\\':
+			if !l.readEscape(&out) {
+				l.error(start, "unterminated string literal")
+				return out.String()
+			}
+		default:
+			out.WriteRune(l.ch)
 		}
 	}
-	return l.input[position:l.position]
 }
 
-func (l *Lexer) peekChar() byte {
+// resolves the escape sequence after a backslash into out, reports false
+// when the input ends in the middle of it
+func (l *Lexer) readEscape(out *strings.Builder) bool {
+	pos := l.currentPosition()
+	l.readChar()
+
+	switch l.ch {
+	case 0:
+		return false
+	case 'n':
+		out.WriteRune('\n')
+	case 't':
+		out.WriteRune('\t')
+	case 'r':
+		out.WriteRune('\r')
+	case '"':
+		out.WriteRune('"')
+	case '\\':
+		out.WriteRune('\\')
+	case 'u':
+		return l.readUnicodeEscape(out, pos)
+	default:
+		l.error(pos, fmt.Sprintf("unknown escape sequence \\%c", l.ch))
+	}
+
+	return true
+}
+
+// reads the `{XXXX}` part of a \u{XXXX} escape
+func (l *Lexer) readUnicodeEscape(out *strings.Builder, pos token.Position) bool {
+	if l.peekChar() != '{' {
+		l.error(pos, "expected { after \\u")
+		return true
+	}
+	l.readChar()
+
+	var digits strings.Builder
+	for l.peekChar() != '}' {
+		if l.peekChar() == 0 || l.peekChar() == '"' {
+			l.error(pos, "unterminated \\u{...} escape")
+			return l.peekChar() != 0
+		}
+		l.readChar()
+		digits.WriteRune(l.ch)
+	}
+	l.readChar()
+
+	value, err := strconv.ParseUint(digits.String(), 16, 32)
+	if err != nil || digits.Len() > 6 || !utf8.ValidRune(rune(value)) {
+		l.error(pos, fmt.Sprintf("invalid unicode escape \\u{%s}", digits.String()))
+		return true
+	}
+
+	out.WriteRune(rune(value))
+	return true
+}
+
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+
+	r, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return r
 }
 
-func (l *Lexer) skipWhiteSpace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+// skips whitespace and comments, the comments are returned so they can be
+// attached to the next token
+func (l *Lexer) skipWhiteSpace() []token.Comment {
+	var comments []token.Comment
+
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			comments = append(comments, l.readLineComment())
+		case l.ch == '/' && l.peekChar() == '*':
+			comments = append(comments, l.readBlockComment())
+		default:
+			return comments
+		}
+	}
+}
+
+// reads a // comment up to, but not including, the end of the line
+func (l *Lexer) readLineComment() token.Comment {
+	pos := l.currentPosition()
+	position := l.position
+
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+
+	return token.Comment{Text: l.input[position:l.position], Pos: pos}
+}
+
+// reads a /* */ comment, nested block comments have to be closed as well
+func (l *Lexer) readBlockComment() token.Comment {
+	pos := l.currentPosition()
+	position := l.position
+	depth := 0
+
+	for {
+		switch {
+		case l.ch == 0:
+			l.error(pos, "unterminated block comment")
+			return token.Comment{Text: l.input[position:l.position], Pos: pos}
+		case l.ch == '/' && l.peekChar() == '*':
+			depth += 1
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth -= 1
+			l.readChar()
+			if depth == 0 {
+				l.readChar()
+				return token.Comment{Text: l.input[position:l.position], Pos: pos}
+			}
+		}
 		l.readChar()
 	}
 }
 
 // new token constructor
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-func (l *Lexer) readNumber() string {
+// reads an INT, or a FLOAT when the digits are followed by a '.' and
+// at least one more digit
+func (l *Lexer) readNumber() token.Token {
 	position := l.position
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+
+	if l.ch != '.' || !isDigit(l.peekChar()) {
+		return token.Token{Type: token.INT, Literal: l.input[position:l.position]}
+	}
+
+	l.readChar()
+	for isDigit(l.ch) {
+		l.readChar()
+	}
+
+	return token.Token{Type: token.FLOAT, Literal: l.input[position:l.position]}
 }
 
-// reads/parses input for identifier
+// reads/parses input for identifier, digits are allowed after the first letter
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
-// checks if input is a valid letter
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// checks if input is a valid letter, any unicode letter counts
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
@@ -86,3 +86,319 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestFloatTokens(t *testing.T) {
+	input := `3.14 10 0.5`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FLOAT, "3.14"},
+		{token.INT, "10"},
+		{token.FLOAT, "0.5"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestAssignTokens(t *testing.T) {
+	input := `x = 1; x += 1; x -= 1; x *= 2; x /= 2;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"}, {token.ASSIGN, "="}, {token.INT, "1"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.PLUS_ASSIGN, "+="}, {token.INT, "1"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.MINUS_ASSIGN, "-="}, {token.INT, "1"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.ASTR_ASSIGN, "*="}, {token.INT, "2"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.SLASH_ASSIGN, "/="}, {token.INT, "2"}, {token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestArrowAndEllipsisTokens(t *testing.T) {
+	input := `_ => x == 1 ...rest.x`
+
+	expected := []token.TokenType{
+		token.IDENT, token.ARROW, token.IDENT, token.EQ, token.INT,
+		token.ELLIPSIS, token.IDENT, token.DOT, token.IDENT, token.EOF,
+	}
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "var x = 10;\n  x += \"a\";"
+
+	tests := []struct {
+		expectedType token.TokenType
+		line         int
+		column       int
+	}{
+		{token.VAR, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 11},
+		{token.IDENT, 2, 3},
+		{token.PLUS_ASSIGN, 2, 5},
+		{token.STRING, 2, 8},
+		{token.SEMICOLON, 2, 11},
+	}
+
+	l := NewWithFile(input, "test.catt")
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos.Line != tt.line || tok.Pos.Column != tt.column {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.line, tt.column, tok.Pos.Line, tok.Pos.Column)
+		}
+		if tok.Pos.File != "test.catt" {
+			t.Fatalf("tests[%d] - file wrong. got=%q", i, tok.Pos.File)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`"tab\there"`, "tab\there"},
+		{`"say \"meow\""`, `say "meow"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"\u{1F431} cat"`, "🐱 cat"},
+		{`"\u{e9}"`, "é"},
+		{`"héllo wörld"`, "héllo wörld"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		errors := []string{}
+		l.SetErrorHandler(func(pos token.Position, msg string) {
+			errors = append(errors, msg)
+		})
+
+		tok := l.NextToken()
+		if tok.Type != token.STRING {
+			t.Fatalf("tokentype wrong. expected=%q, got=%q", token.STRING, tok.Type)
+		}
+		if tok.Literal != tt.expected {
+			t.Errorf("literal wrong. expected=%q, got=%q", tt.expected, tok.Literal)
+		}
+		if len(errors) != 0 {
+			t.Errorf("unexpected lexer errors for %s: %v", tt.input, errors)
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		line     int
+		column   int
+	}{
+		{`var s = "never closed`, "unterminated string literal", 1, 9},
+		{`"bad \q escape"`, `unknown escape sequence \q`, 1, 6},
+		{`"\u{110000}"`, `invalid unicode escape \u{110000}`, 1, 2},
+		{"\"ok\";\n\"a\\", "unterminated string literal", 2, 1},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		var errPos token.Position
+		errors := []string{}
+		l.SetErrorHandler(func(pos token.Position, msg string) {
+			if len(errors) == 0 {
+				errPos = pos
+			}
+			errors = append(errors, msg)
+		})
+
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		if len(errors) == 0 {
+			t.Errorf("expected a lexer error for %s", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0])
+		}
+		if errPos.Line != tt.line || errPos.Column != tt.column {
+			t.Errorf("wrong position. expected=%d:%d, got=%d:%d", tt.line, tt.column, errPos.Line, errPos.Column)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `var 猫 = "ニャー"; var café = 猫;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		column          int
+	}{
+		{token.VAR, "var", 1},
+		{token.IDENT, "猫", 5},
+		{token.ASSIGN, "=", 7},
+		{token.STRING, "ニャー", 9},
+		{token.SEMICOLON, ";", 14},
+		{token.VAR, "var", 16},
+		{token.IDENT, "café", 20},
+		{token.ASSIGN, "=", 25},
+		{token.IDENT, "猫", 27},
+		{token.SEMICOLON, ";", 28},
+		{token.EOF, "", 29},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos.Column != tt.column {
+			t.Fatalf("tests[%d] - column wrong. expected=%d, got=%d",
+				i, tt.column, tok.Pos.Column)
+		}
+	}
+}
+
+func TestDigitsInIdentifiers(t *testing.T) {
+	input := `v1 x2y 1x`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "v1"},
+		{token.IDENT, "x2y"},
+		{token.INT, "1"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading note
+var x = 5; // trailing
+/* block /* nested */ still comment */ x /= 2;
+/**/ x
+// at the end`
+
+	tests := []struct {
+		expectedType     token.TokenType
+		expectedLiteral  string
+		expectedComments []string
+	}{
+		{token.VAR, "var", []string{"// leading note"}},
+		{token.IDENT, "x", nil},
+		{token.ASSIGN, "=", nil},
+		{token.INT, "5", nil},
+		{token.SEMICOLON, ";", nil},
+		{token.IDENT, "x", []string{"// trailing", "/* block /* nested */ still comment */"}},
+		{token.SLASH_ASSIGN, "/=", nil},
+		{token.INT, "2", nil},
+		{token.SEMICOLON, ";", nil},
+		{token.IDENT, "x", []string{"/**/"}},
+		{token.EOF, "", []string{"// at the end"}},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+		if len(tok.Comments) != len(tt.expectedComments) {
+			t.Fatalf("tests[%d] - wrong number of comments. expected=%d, got=%d",
+				i, len(tt.expectedComments), len(tok.Comments))
+		}
+		for j, comment := range tt.expectedComments {
+			if tok.Comments[j].Text != comment {
+				t.Fatalf("tests[%d] - comment wrong. expected=%q, got=%q",
+					i, comment, tok.Comments[j].Text)
+			}
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("var x = 1;\n/* outer /* inner */ never closed")
+	var errPos token.Position
+	errors := []string{}
+	l.SetErrorHandler(func(pos token.Position, msg string) {
+		errPos = pos
+		errors = append(errors, msg)
+	})
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+
+	if len(errors) != 1 || errors[0] != "unterminated block comment" {
+		t.Fatalf("wrong errors. got=%v", errors)
+	}
+	if errPos.Line != 2 || errPos.Column != 1 {
+		t.Errorf("wrong position. expected=2:1, got=%d:%d", errPos.Line, errPos.Column)
+	}
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"go_interpreter/ast"
+	"go_interpreter/compiler"
 	"go_interpreter/evaluator"
 	"go_interpreter/lexer"
 	"go_interpreter/object"
 	"go_interpreter/parser"
 	"go_interpreter/repl"
-	"io"
+	"go_interpreter/vm"
 	"os"
 	"os/user"
 )
@@ -18,30 +21,49 @@ func check(e error) {
 	}
 }
 
+// runs program with the tree walking evaluator or, with -backend=vm, with
+// the bytecode compiler and vm
+func run(program *ast.Program, backend string) object.Object {
+	if backend != "vm" {
+		return evaluator.Eval(program, object.NewEnvironment())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		fmt.Println(err)
+		return nil
+	}
+
+	return vm.New(comp.Bytecode()).Run()
+}
+
 func main() {
-	if len(os.Args) == 2 {
+	backend := flag.String("backend", "eval", "how files are run: eval or vm")
+	flag.Parse()
+
+	if flag.NArg() == 1 {
 
 		// f, err := os.Open(os.Args[1])
 		// check(err)
 
-		text, err := os.ReadFile(os.Args[1])
+		file := flag.Arg(0)
+		text, err := os.ReadFile(file)
 		check(err)
-		env := object.NewEnvironment()
 		line := string(text)
-		l := lexer.New(line)
+		l := lexer.NewWithFile(line, file)
 		p := parser.New(l)
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(os.Stdout, p.Errors())
+			repl.PrintParserErrors(os.Stdout, line, p.ParseErrors())
+			return
 		}
 		// io.WriteString(os.Stdout, program.String())
 		// io.WriteString(os.Stdout, "\n")
 
-		evaluated := evaluator.Eval(program, env)
+		evaluated := run(program, *backend)
 		if evaluated != nil {
-			if evaluated.Type() == object.ERROR_OBJ {
-				io.WriteString(os.Stdout, evaluated.Inspect())
-				io.WriteString(os.Stdout, "\n")
+			if err, ok := evaluated.(*object.Error); ok {
+				repl.PrintRuntimeError(os.Stdout, line, err)
 			}
 		}
 	} else {
@@ -59,9 +81,3 @@ func main() {
 		repl.Start(os.Stdin, os.Stdout)
 	}
 }
-
-func printParserErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
-	}
-}
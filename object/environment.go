@@ -1,22 +1,86 @@
 package object
 
+// a scope inside outer, every block and loop iteration makes one so only
+// its own bindings are allocated
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-
-	return env
+	return &Environment{
+		store:    make(map[string]Object),
+		consts:   make(map[string]bool),
+		outer:    outer,
+		modules:  outer.modules,
+		builtins: outer.builtins,
+		calls:    outer.calls,
+	}
 }
 
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	b := make(map[string]Object)
+	c := make(map[string]bool)
+	return &Environment{store: s, consts: c, outer: nil, modules: NewModuleCache(), builtins: b, calls: new(int)}
+}
+
+// a top-level scope for an imported file, it sees none of the importer's
+// bindings but shares its module cache so every file is loaded only once,
+// and its builtins
+func NewModuleEnvironment(importer *Environment) *Environment {
+	env := NewEnvironment()
+	env.modules = importer.modules
+	env.builtins = importer.builtins
+	env.calls = importer.calls
+
+	return env
 }
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store   map[string]Object
+	consts  map[string]bool
+	outer   *Environment
+	modules *ModuleCache
+
+	// builtins added or replaced for this program, seen by every scope and
+	// every imported module
+	builtins map[string]Object
+
+	// the function calls of this program in progress
+	calls *int
+}
+
+func (e *Environment) Modules() *ModuleCache {
+	return e.modules
+}
+
+// counts a call made by a function of this program, reports false without
+// counting it when limit calls are already in progress
+func (e *Environment) EnterCall(limit int) bool {
+	if *e.calls >= limit {
+		return false
+	}
+	*e.calls++
+	return true
+}
+
+func (e *Environment) LeaveCall() {
+	*e.calls--
 }
 
+func (e *Environment) SetBuiltin(name string, val Object) {
+	e.builtins[name] = val
+}
+
+func (e *Environment) Builtin(name string) (Object, bool) {
+	obj, ok := e.builtins[name]
+	return obj, ok
+}
+
+// only the bindings of this scope, outer scopes are not consulted. A hoisted
+// name is left out until its declaration ran
+func (e *Environment) GetLocal(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	return obj, ok && obj != nil
+}
+
+// a hoisted name is found with a nil value until its declaration ran
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 	if !ok && e.outer != nil {
@@ -27,5 +91,120 @@ func (e *Environment) Get(name string) (Object, bool) {
 
 func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
+	delete(e.consts, name)
 	return val
 }
+
+// binds name in this scope for a declaration, reports false without
+// binding it when this scope already declared name as a constant
+func (e *Environment) Define(name string, val Object, constant bool) bool {
+	if e.consts[name] {
+		return false
+	}
+
+	e.store[name] = val
+	if constant {
+		e.consts[name] = true
+	} else {
+		delete(e.consts, name)
+	}
+	return true
+}
+
+// declares name in this scope before its declaration runs, so it hides the
+// bindings of outer scopes from then on. Names already bound are left alone
+func (e *Environment) Hoist(name string) {
+	if _, ok := e.store[name]; !ok {
+		e.store[name] = nil
+	}
+}
+
+// whether the nearest scope that declares name made it a constant
+func (e *Environment) IsConst(name string) bool {
+	if _, ok := e.store[name]; ok {
+		return e.consts[name]
+	}
+
+	if e.outer != nil {
+		return e.outer.IsConst(name)
+	}
+
+	return false
+}
+
+// a scope next to this one with the same bindings, a for loop makes one
+// before every increment so closures of an iteration keep their values
+func (e *Environment) Copy() *Environment {
+	env := &Environment{
+		store:    make(map[string]Object, len(e.store)),
+		consts:   make(map[string]bool, len(e.consts)),
+		outer:    e.outer,
+		modules:  e.modules,
+		builtins: e.builtins,
+		calls:    e.calls,
+	}
+
+	for name, val := range e.store {
+		env.store[name] = val
+	}
+	for name := range e.consts {
+		env.consts[name] = true
+	}
+
+	return env
+}
+
+// updates an existing binding in the nearest scope that declares it,
+// reports false when no scope declares the name
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return val, true
+	}
+
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+
+	return nil, false
+}
+
+// modules loaded by one program keyed by their absolute path, loading holds
+// the chain of imports currently being evaluated to detect cycles
+type ModuleCache struct {
+	modules map[string]*Module
+	loading []string
+}
+
+func NewModuleCache() *ModuleCache {
+	return &ModuleCache{modules: make(map[string]*Module)}
+}
+
+func (mc *ModuleCache) Get(path string) (*Module, bool) {
+	module, ok := mc.modules[path]
+	return module, ok
+}
+
+func (mc *ModuleCache) Set(path string, module *Module) {
+	mc.modules[path] = module
+}
+
+// marks path as being loaded, when path is already on the chain the cycle
+// is returned and nothing is recorded
+func (mc *ModuleCache) Begin(path string) []string {
+	for i, loading := range mc.loading {
+		if loading == path {
+			cycle := append([]string{}, mc.loading[i:]...)
+			return append(cycle, path)
+		}
+	}
+
+	mc.loading = append(mc.loading, path)
+	return nil
+}
+
+func (mc *ModuleCache) End(path string) {
+	if n := len(mc.loading); n > 0 && mc.loading[n-1] == path {
+		mc.loading = mc.loading[:n-1]
+	}
+}
@@ -4,6 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"go_interpreter/ast"
+	"go_interpreter/code"
+	"go_interpreter/token"
+	"hash/fnv"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -16,6 +21,7 @@ type Object interface {
 
 const (
 	INTEGER_OBJ    = "INTEGER"
+	FLOAT_OBJ      = "FLOAT"
 	BOOL_OBJ       = "BOOLEAN"
 	NULL_OBJ       = "NULL"
 	ERROR_OBJ      = "ERROR"
@@ -24,8 +30,110 @@ const (
 	FUNCTION_OBJ   = "FUNCTION_OBJ"
 	BUILTIN_OBJ    = "BUILTIN"
 	ARRAY_OBJ      = "ARRAY"
+	HASH_OBJ       = "HASH"
+	BREAK_OBJ      = "BREAK"
+	CONTINUE_OBJ   = "CONTINUE"
+	MODULE_OBJ     = "MODULE"
+	STRUCT_OBJ     = "STRUCT"
+	RECORD_OBJ     = "RECORD"
+	TAIL_CALL_OBJ  = "TAIL_CALL"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION_OBJ"
+	CLOSURE_OBJ           = "CLOSURE"
 )
 
+// any object that can be used as a key in a hash literal
+type Hashable interface {
+	HashKey() HashKey
+}
+
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+
+	if b.Value {
+		value = 1
+	} else {
+		value = 0
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+
+func (h *Hash) Inspect() string {
+	return h.inspect(nil)
+}
+
+func (h *Hash) inspect(visiting map[Object]bool) string {
+	if visiting[h] {
+		return "{...}"
+	}
+	visiting = enter(visiting, h)
+	defer delete(visiting, h)
+
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.Inspect()+": "+inspectIn(pair.Value, visiting))
+	}
+	// map iteration order is random, keep the output stable
+	sort.Strings(pairs)
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// arrays, hashes and records can end up holding themselves, the ones being
+// inspected further up are printed as a placeholder instead of recursing
+type container interface {
+	inspect(visiting map[Object]bool) string
+}
+
+func inspectIn(obj Object, visiting map[Object]bool) string {
+	if c, ok := obj.(container); ok {
+		return c.inspect(visiting)
+	}
+	return obj.Inspect()
+}
+
+func enter(visiting map[Object]bool, obj Object) map[Object]bool {
+	if visiting == nil {
+		visiting = make(map[Object]bool)
+	}
+	visiting[obj] = true
+	return visiting
+}
+
 type Array struct {
 	Elements []Object
 }
@@ -33,11 +141,21 @@ type Array struct {
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
 
 func (ao *Array) Inspect() string {
+	return ao.inspect(nil)
+}
+
+func (ao *Array) inspect(visiting map[Object]bool) string {
+	if visiting[ao] {
+		return "[...]"
+	}
+	visiting = enter(visiting, ao)
+	defer delete(visiting, ao)
+
 	var out bytes.Buffer
 	elements := []string{}
 
 	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
+		elements = append(elements, inspectIn(e, visiting))
 	}
 
 	out.WriteString("[")
@@ -47,6 +165,75 @@ func (ao *Array) Inspect() string {
 	return out.String()
 }
 
+// an imported file, its top-level bindings live in Env
+type Module struct {
+	Name string
+	Path string
+	Env  *Environment
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+
+func (m *Module) Inspect() string {
+	return "module " + m.Name + " (" + m.Path + ")"
+}
+
+// the type made by a struct statement, calling it builds a Record
+type StructType struct {
+	Name   string
+	Fields []string
+}
+
+func (st *StructType) Type() ObjectType { return STRUCT_OBJ }
+
+func (st *StructType) Inspect() string {
+	return "struct " + st.Name + " { " + strings.Join(st.Fields, ", ") + " }"
+}
+
+// the field index of name, -1 when the struct has no such field
+func (st *StructType) FieldIndex(name string) int {
+	for i, field := range st.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// a value of a StructType, Values are in the order of the type's Fields
+type Record struct {
+	Struct *StructType
+	Values []Object
+}
+
+func (r *Record) Type() ObjectType { return RECORD_OBJ }
+
+func (r *Record) Inspect() string {
+	return r.inspect(nil)
+}
+
+func (r *Record) inspect(visiting map[Object]bool) string {
+	if visiting[r] {
+		return r.Struct.Name + "{...}"
+	}
+	visiting = enter(visiting, r)
+	defer delete(visiting, r)
+
+	var out bytes.Buffer
+
+	fields := []string{}
+	for i, field := range r.Struct.Fields {
+		fields = append(fields, field+": "+inspectIn(r.Values[i], visiting))
+	}
+
+	out.WriteString(r.Struct.Name)
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
 type BuiltInFunction func(args ...Object) Object
 
 type BuiltIn struct {
@@ -61,8 +248,20 @@ func (bi *BuiltIn) Inspect() string {
 	return "builtin"
 }
 
+// a function of another backend, such as a vm closure handed to a builtin,
+// that the evaluator can call like its own functions. frame describes the
+// call like it does for the evaluator's functions
+type Callable interface {
+	Object
+	Call(frame Frame, args ...Object) Object
+}
+
 type Function struct {
+	// set for functions made by a fn statement
+	Name       string
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -74,14 +273,12 @@ func (f *Function) Type() ObjectType {
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.FormatParameters(f.Parameters, f.Defaults, f.Rest))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
@@ -89,6 +286,66 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// a function body lowered to bytecode by the compiler package
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	Name          string
+	SourceMap     code.SourceMap
+
+	// how many of the last parameters have a default value
+	NumDefaults int
+	// whether the extra arguments are passed as an array in the local
+	// after the parameters
+	Variadic bool
+	// where a call starts, indexed by the number of defaulted parameters
+	// it was given. The defaults of the ones left out are computed first
+	EntryPoints []int
+
+	// names behind the local and free slots, used in error messages
+	LocalNames []string
+	FreeNames  []string
+
+	// made by a fn statement rather than bound by var, only such a Name is
+	// kept when a builtin calls the function back, as in the evaluator
+	Declared bool
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION_OBJ
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// a CompiledFunction together with the variables it captured, the vm keeps
+// each captured variable in a shared cell so assignments are seen by all
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+
+	// runs the closure on the vm that made it, for builtins like map that
+	// call it back
+	Run func(cl *Closure, frame Frame, args ...Object) Object
+}
+
+func (c *Closure) Call(frame Frame, args ...Object) Object {
+	return c.Run(c, frame, args...)
+}
+
+func (c *Closure) Type() ObjectType {
+	return CLOSURE_OBJ
+}
+
+func (c *Closure) Inspect() string {
+	if c.Fn.Name != "" {
+		return "fn " + c.Fn.Name
+	}
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
 type ReturnValue struct {
 	Value Object
 }
@@ -101,6 +358,51 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// sentinels produced by break/continue, they unwind blocks until a loop
+// with a matching (or empty) label handles them. Pos is the statement, for
+// the error when no loop does
+type Break struct {
+	Label string
+	Pos   token.Position
+}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+type Continue struct {
+	Label string
+	Pos   token.Position
+}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+// a call in tail position that has not been made yet, applyFunction runs
+// it in a loop instead of recursing so deep tail recursion keeps a flat stack
+type TailCall struct {
+	Function  Object
+	Arguments []Object
+	Frame     Frame
+}
+
+func (tc *TailCall) Type() ObjectType {
+	return TAIL_CALL_OBJ
+}
+
+func (tc *TailCall) Inspect() string {
+	return "tail call " + tc.Frame.Function
+}
+
 type String struct {
 	Value string
 }
@@ -115,6 +417,25 @@ func (s *String) Inspect() string {
 
 type Error struct {
 	Message string
+	// where the error was raised, set by the evaluator
+	Pos token.Position
+	// the calls the error unwound through, innermost first
+	Stack []Frame
+	// the value given to throw, nil when the interpreter raised the error
+	Thrown Object
+}
+
+// one function call on the way to an error, Pos is the call site
+type Frame struct {
+	Function string
+	Pos      token.Position
+}
+
+func (f Frame) String() string {
+	if !f.Pos.IsValid() {
+		return f.Function
+	}
+	return f.Function + " (" + f.Pos.String() + ")"
 }
 
 func (e *Error) Type() ObjectType {
@@ -158,3 +479,20 @@ func (i *Integer) Inspect() string {
 func (i *Integer) Type() ObjectType {
 	return INTEGER_OBJ
 }
+
+type Float struct {
+	Value float64
+}
+
+// whole floats keep a trailing ".0" so they don't read as integers
+func (f *Float) Inspect() string {
+	str := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(str, ".eEnN") {
+		str += ".0"
+	}
+	return str
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
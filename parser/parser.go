@@ -5,7 +5,9 @@ import (
 	"go_interpreter/ast"
 	"go_interpreter/lexer"
 	"go_interpreter/token"
+	"path"
 	"strconv"
+	"strings"
 )
 
 type (
@@ -16,6 +18,9 @@ type (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN
+	LOGICALOR
+	LOGICALAND
 	EQUALS
 	LESSGREATER
 	SUM
@@ -26,19 +31,27 @@ const (
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.AND:      LESSGREATER,
-	token.OR:       LESSGREATER,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.MINUS:    SUM,
-	token.PLUS:     SUM,
-	token.ASTR:     PRODUCT,
-	token.SLASH:    PRODUCT,
-	token.MODULO:   PRODUCT,
-	token.LPAR:     CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:       ASSIGN,
+	token.PLUS_ASSIGN:  ASSIGN,
+	token.MINUS_ASSIGN: ASSIGN,
+	token.ASTR_ASSIGN:  ASSIGN,
+	token.SLASH_ASSIGN: ASSIGN,
+	token.EQ:           EQUALS,
+	token.NOT_EQ:       EQUALS,
+	token.OR:           LOGICALOR,
+	token.AND:          LOGICALAND,
+	token.LT:           LESSGREATER,
+	token.GT:           LESSGREATER,
+	token.LT_EQ:        LESSGREATER,
+	token.GT_EQ:        LESSGREATER,
+	token.MINUS:        SUM,
+	token.PLUS:         SUM,
+	token.ASTR:         PRODUCT,
+	token.SLASH:        PRODUCT,
+	token.MODULO:       PRODUCT,
+	token.LPAR:         CALL,
+	token.LBRACKET:     INDEX,
+	token.DOT:          INDEX,
 }
 
 func (p *Parser) registerPrefixFn(tokenType token.TokenType, fn prefixParserFn) {
@@ -53,32 +66,36 @@ type Parser struct {
 	l         *lexer.Lexer
 	currToken token.Token
 	aftToken  token.Token
-	errors    []string
+	errors    []*ParseError
 
 	prefixParserFns map[token.TokenType]prefixParserFn
 	infixParserFns  map[token.TokenType]infixParserFn
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, errors: []*ParseError{}}
+	l.SetErrorHandler(p.addError)
 	p.NextToken()
 	p.NextToken()
 
 	p.prefixParserFns = make(map[token.TokenType]prefixParserFn)
 	p.registerPrefixFn(token.IDENT, p.parseIdentifier)
 	p.registerPrefixFn(token.INT, p.parseIntegerLiteral)
+	p.registerPrefixFn(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefixFn(token.BANG, p.parsePrefixExpression)
 	p.registerPrefixFn(token.MINUS, p.parsePrefixExpression)
-	p.registerPrefixFn(token.ASSIGN, p.parsePrefixExpression)
 	p.registerPrefixFn(token.FALSE, p.parseBoolean)
 	p.registerPrefixFn(token.TRUE, p.parseBoolean)
 	p.registerPrefixFn(token.LPAR, p.parseParExpression)
 	p.registerPrefixFn(token.IF, p.parseIfExpression)
+	p.registerPrefixFn(token.TRY, p.parseTryExpression)
+	p.registerPrefixFn(token.MATCH, p.parseMatchExpression)
 	p.registerPrefixFn(token.STRING, p.ParseString)
 	p.registerPrefixFn(token.WHILE, p.ParseWhileExpression)
 	p.registerPrefixFn(token.FOR, p.ParseForExpression)
 	p.registerPrefixFn(token.FUNCTION, p.ParseFunctionLiteral)
 	p.registerPrefixFn(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefixFn(token.LBRAC, p.parseHashLiteral)
 
 	p.infixParserFns = make(map[token.TokenType]infixParserFn)
 	p.registerInfixFn(token.PLUS, p.parseInfixExpression)
@@ -91,18 +108,47 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfixFn(token.OR, p.parseInfixExpression)
 	p.registerInfixFn(token.LT, p.parseInfixExpression)
 	p.registerInfixFn(token.GT, p.parseInfixExpression)
+	p.registerInfixFn(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfixFn(token.GT_EQ, p.parseInfixExpression)
 	p.registerInfixFn(token.MODULO, p.parseInfixExpression)
 	p.registerInfixFn(token.LPAR, p.ParseCallExpression)
 	p.registerInfixFn(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfixFn(token.DOT, p.parseMemberExpression)
+	p.registerInfixFn(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.ASTR_ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.SLASH_ASSIGN, p.parseAssignExpression)
 
 	return p
 }
 
+// left[index], or a slice left[low:high] when a colon follows the index
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.currToken, Left: left}
+	tkn := p.currToken
+	var index ast.Expression
 
 	p.NextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	if p.currToken.Type != token.COLON {
+		index = p.parseExpression(LOWEST)
+		if p.aftToken.Type != token.COLON {
+			if !p.PeekAndMove(token.RBRACKET) {
+				return nil
+			}
+			return &ast.IndexExpression{Token: tkn, Left: left, Index: index}
+		}
+		p.NextToken()
+	}
+
+	exp := &ast.SliceExpression{Token: tkn, Left: left, Low: index}
+
+	if p.aftToken.Type == token.RBRACKET {
+		p.NextToken()
+		return exp
+	}
+
+	p.NextToken()
+	exp.High = p.parseExpression(LOWEST)
 
 	if !p.PeekAndMove(token.RBRACKET) {
 		return nil
@@ -111,6 +157,51 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.currToken, Object: left}
+
+	if !p.PeekAndMove(token.IDENT) {
+		return nil
+	}
+
+	exp.Property = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+	return exp
+}
+
+// block statements never reach parseExpression (they are consumed by
+// parseBlockStatement after a PeekAndMove), so a '{' in expression position
+// is always a hash literal
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.currToken}
+	hash.Pairs = make(map[ast.Expression]ast.Expression)
+
+	for p.aftToken.Type != token.RBRAC {
+		p.NextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.PeekAndMove(token.COLON) {
+			return nil
+		}
+
+		p.NextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
+
+		if p.aftToken.Type != token.RBRAC && !p.PeekAndMove(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.PeekAndMove(token.RBRAC) {
+		return nil
+	}
+
+	return hash
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.currToken}
 
@@ -181,47 +272,139 @@ func (p *Parser) ParseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	fnc.Parameters = p.ParseFunctionParameters()
+	if !p.ParseFunctionParameters(fnc) {
+		return nil
+	}
 
 	if !p.PeekAndMove(token.LBRAC) {
 		return nil
 	}
 
 	fnc.Body = p.parseBlockStatement()
+	markTailCalls(fnc.Body)
 
 	return fnc
 }
 
-func (p *Parser) ParseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// flags the calls in tail position of a function body, that is the value of
+// the last statement (following if/else branches) and of every return
+func markTailCalls(body *ast.BlockStatement) {
+	markTailStatement(lastStatement(body))
+	markTailReturns(body)
+}
 
-	if p.aftToken.Type == token.RPAR {
-		p.NextToken()
-		return identifiers
+func markTailStatement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		markTailExpression(stmt.Expression)
+	case *ast.ReturnStatement:
+		markTailExpression(stmt.Value)
 	}
+}
 
-	p.NextToken()
+func markTailExpression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.CallExpression:
+		exp.Tail = true
+	case *ast.IfExpression:
+		markTailStatement(lastStatement(exp.Consequence))
+		markTailExpression(exp.CondAlternative)
+		markTailStatement(lastStatement(exp.Alternative))
+	case *ast.MatchExpression:
+		for _, arm := range exp.Arms {
+			markTailStatement(lastStatement(arm.Body))
+		}
+	}
+}
 
-	identifier := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
-	identifiers = append(identifiers, identifier)
+// returns leave the function no matter how deeply they sit inside loops or
+// conditionals, nested function literals are marked when they are parsed.
+// Calls inside try are never marked, the try has to see their errors
+func markTailReturns(block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
 
-	for p.aftToken.Type == token.COMMA {
-		p.NextToken()
-		p.NextToken()
-		identifier := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
-		identifiers = append(identifiers, identifier)
+	for _, stmt := range block.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.ReturnStatement:
+			markTailExpression(stmt.Value)
+		case *ast.ExpressionStatement:
+			markTailReturnsIn(stmt.Expression)
+		}
+	}
+}
 
+func markTailReturnsIn(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.IfExpression:
+		markTailReturns(exp.Consequence)
+		markTailReturnsIn(exp.CondAlternative)
+		markTailReturns(exp.Alternative)
+	case *ast.WhileExpression:
+		markTailReturns(exp.Consequence)
+	case *ast.ForExpression:
+		markTailReturns(exp.Consequence)
+	case *ast.MatchExpression:
+		for _, arm := range exp.Arms {
+			markTailReturns(arm.Body)
+		}
 	}
+}
 
-	if !p.PeekAndMove(token.RPAR) {
+func lastStatement(block *ast.BlockStatement) ast.Statement {
+	if block == nil || len(block.Statements) == 0 {
 		return nil
 	}
+	return block.Statements[len(block.Statements)-1]
+}
 
-	return identifiers
+// fills the parameters, defaults and rest parameter of fnc. Parameters with
+// a default value come after the ones without and ...rest comes last
+func (p *Parser) ParseFunctionParameters(fnc *ast.FunctionLiteral) bool {
+	fnc.Parameters = []*ast.Identifier{}
+
+	for p.aftToken.Type != token.RPAR {
+		if p.aftToken.Type == token.ELLIPSIS {
+			p.NextToken()
+			if !p.PeekAndMove(token.IDENT) {
+				return false
+			}
+			fnc.Rest = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+			if p.aftToken.Type != token.RPAR {
+				p.addError(p.aftToken.Pos, "rest parameter must be the last parameter")
+				return false
+			}
+			break
+		}
+
+		if !p.PeekAndMove(token.IDENT) {
+			return false
+		}
+		param := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+		fnc.Parameters = append(fnc.Parameters, param)
+
+		if p.aftToken.Type == token.ASSIGN {
+			p.NextToken()
+			p.NextToken()
+			fnc.Defaults = append(fnc.Defaults, p.parseExpression(LOWEST))
+		} else if len(fnc.Defaults) > 0 {
+			p.addError(param.Pos(), fmt.Sprintf("missing default value for parameter %s", param.Value))
+			return false
+		}
+
+		if p.aftToken.Type != token.COMMA {
+			break
+		}
+		p.NextToken()
+	}
+
+	return p.PeekAndMove(token.RPAR)
 }
 
 func (p *Parser) ParseString() ast.Expression {
-	return &ast.String{p.currToken, p.currToken.Literal}
+	return &ast.String{Token: p.currToken, Value: p.currToken.Literal}
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
@@ -338,6 +521,139 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expr
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	expr := &ast.TryExpression{Token: p.currToken}
+
+	if !p.PeekAndMove(token.LBRAC) {
+		return nil
+	}
+
+	expr.Block = p.parseBlockStatement()
+
+	if p.aftToken.Type == token.CATCH {
+		p.NextToken()
+
+		if !p.PeekAndMove(token.LPAR) {
+			return nil
+		}
+		if !p.PeekAndMove(token.IDENT) {
+			return nil
+		}
+		expr.Param = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+		if !p.PeekAndMove(token.RPAR) {
+			return nil
+		}
+
+		if !p.PeekAndMove(token.LBRAC) {
+			return nil
+		}
+		expr.Catch = p.parseBlockStatement()
+	}
+
+	if p.aftToken.Type == token.FINALLY {
+		p.NextToken()
+
+		if !p.PeekAndMove(token.LBRAC) {
+			return nil
+		}
+		expr.Finally = p.parseBlockStatement()
+	}
+
+	if expr.Catch == nil && expr.Finally == nil {
+		p.addError(p.aftToken.Pos, fmt.Sprintf("expected catch or finally after try, got %s instead", p.aftToken.Type))
+		return nil
+	}
+
+	return expr
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expr := &ast.MatchExpression{Token: p.currToken}
+
+	p.NextToken()
+	expr.Value = p.parseExpression(LOWEST)
+
+	if !p.PeekAndMove(token.LBRAC) {
+		return nil
+	}
+
+	for p.aftToken.Type != token.RBRAC {
+		p.NextToken()
+
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expr.Arms = append(expr.Arms, arm)
+
+		// arms are separated by commas, which may be left out after a block
+		if p.aftToken.Type == token.COMMA {
+			p.NextToken()
+		} else if p.currToken.Type != token.RBRAC {
+			break
+		}
+	}
+
+	if !p.PeekAndMove(token.RBRAC) {
+		return nil
+	}
+
+	return expr
+}
+
+// the body of an arm is a block or a single expression
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Pattern: p.parseExpression(LOWEST)}
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	if !isPattern(arm.Pattern) {
+		p.addError(arm.Pattern.Pos(), fmt.Sprintf("invalid pattern: %s", arm.Pattern.String()))
+		return nil
+	}
+
+	if !p.PeekAndMove(token.ARROW) {
+		return nil
+	}
+
+	if p.aftToken.Type == token.LBRAC {
+		p.NextToken()
+		arm.Body = p.parseBlockStatement()
+		return arm
+	}
+
+	p.NextToken()
+	stmt := &ast.ExpressionStatement{Token: p.currToken, Expression: p.parseExpression(LOWEST)}
+	arm.Body = &ast.BlockStatement{Token: stmt.Token, Statements: []ast.Statement{stmt}}
+
+	return arm
+}
+
+// literals (a negative number counts as one), identifiers and arrays of
+// patterns
+func isPattern(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.String, *ast.Boolean, *ast.Identifier:
+		return true
+	case *ast.PrefixExpression:
+		switch exp.Right.(type) {
+		case *ast.IntegerLiteral, *ast.FloatLiteral:
+			return exp.Operator == "-"
+		}
+		return false
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			if !isPattern(el) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
 func (p *Parser) parseParExpression() ast.Expression {
 	p.NextToken()
 	expr := p.parseExpression(LOWEST)
@@ -368,6 +684,30 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// assignment is right associative, the value is parsed from LOWEST so it
+// takes everything after the operator and `a = b = 1` groups as `a = (b = 1)`
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.currToken,
+		Operator: p.currToken.Literal,
+	}
+
+	switch left.(type) {
+	case *ast.Identifier, *ast.IndexExpression, *ast.MemberExpression:
+		expression.Target = left
+	default:
+		msg := fmt.Sprintf("cannot assign to %s", left)
+		p.addError(p.currToken.Pos, msg)
+		return nil
+	}
+
+	p.NextToken()
+
+	expression.Value = p.parseExpression(LOWEST)
+
+	return expression
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
 		Token:    p.currToken,
@@ -381,18 +721,45 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	return expression
 }
 
+// a syntax error together with the position of the offending token
+type ParseError struct {
+	Pos     token.Position
+	Message string
+}
+
+func (pe *ParseError) Error() string {
+	if !pe.Pos.IsValid() {
+		return pe.Message
+	}
+	return pe.Pos.String() + ": " + pe.Message
+}
+
+// error messages prefixed with their position, see ParseErrors for the
+// structured form
 func (p *Parser) Errors() []string {
+	msgs := []string{}
+	for _, err := range p.errors {
+		msgs = append(msgs, err.Error())
+	}
+	return msgs
+}
+
+func (p *Parser) ParseErrors() []*ParseError {
 	return p.errors
 }
 
+func (p *Parser) addError(pos token.Position, msg string) {
+	p.errors = append(p.errors, &ParseError{Pos: pos, Message: msg})
+}
+
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected %s as aftToken, got %s instead", t, p.aftToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.aftToken.Pos, msg)
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function found for %s", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.currToken.Pos, msg)
 }
 
 func (p *Parser) NextToken() {
@@ -417,20 +784,219 @@ func (p *Parser) ParseProgram() *ast.Program {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{p.currToken, p.currToken.Literal}
+	return &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
 }
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.currToken.Type {
-	case token.VAR:
+	case token.VAR, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.STRUCT:
+		return p.parseStructStatement()
+	case token.FUNCTION:
+		if p.aftToken.Type == token.IDENT {
+			return p.parseFunctionStatement()
+		}
+		return p.parseExpressionStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
+	case token.IDENT:
+		if p.aftToken.Type == token.COLON {
+			return p.parseLabeledStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
+// parses `label: while (...) {}` and `label: for (...) {}`, the label is
+// stored on the loop itself so break/continue can target it
+func (p *Parser) parseLabeledStatement() ast.Statement {
+	label := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+	p.NextToken()
+	p.NextToken()
+
+	stmt := &ast.ExpressionStatement{Token: p.currToken}
+
+	switch p.currToken.Type {
+	case token.WHILE:
+		loop, ok := p.ParseWhileExpression().(*ast.WhileExpression)
+		if !ok {
+			return nil
+		}
+		loop.Label = label
+		stmt.Expression = loop
+	case token.FOR:
+		loop, ok := p.ParseForExpression().(*ast.ForExpression)
+		if !ok {
+			return nil
+		}
+		loop.Label = label
+		stmt.Expression = loop
+	default:
+		msg := fmt.Sprintf("label %s must be followed by a loop, got %s instead", label.Value, p.currToken.Type)
+		p.addError(p.currToken.Pos, msg)
+		return nil
+	}
+
+	if p.aftToken.Type == token.SEMICOLON {
+		p.NextToken()
+	}
+
+	return stmt
+}
+
+// fn name(params) { body }, bound before the rest of its block runs
+func (p *Parser) parseFunctionStatement() ast.Statement {
+	stmt := &ast.FunctionStatement{Token: p.currToken}
+
+	p.NextToken()
+	stmt.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+	fnc, ok := p.ParseFunctionLiteral().(*ast.FunctionLiteral)
+	if !ok {
+		return nil
+	}
+	fnc.Token = stmt.Token
+	stmt.Function = fnc
+
+	if p.aftToken.Type == token.SEMICOLON {
+		p.NextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseStructStatement() ast.Statement {
+	stmt := &ast.StructStatement{Token: p.currToken}
+
+	if !p.PeekAndMove(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+	if !p.PeekAndMove(token.LBRAC) {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for p.aftToken.Type != token.RBRAC {
+		if !p.PeekAndMove(token.IDENT) {
+			return nil
+		}
+
+		field := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+		if seen[field.Value] {
+			msg := fmt.Sprintf("duplicate field %s in struct %s", field.Value, stmt.Name.Value)
+			p.addError(field.Pos(), msg)
+			return nil
+		}
+		seen[field.Value] = true
+		stmt.Fields = append(stmt.Fields, field)
+
+		if p.aftToken.Type != token.COMMA {
+			break
+		}
+		p.NextToken()
+	}
+
+	if !p.PeekAndMove(token.RBRAC) {
+		return nil
+	}
+
+	if p.aftToken.Type == token.SEMICOLON {
+		p.NextToken()
+	}
+
+	return stmt
+}
+
+// import "path/to/file.catt" as name;
+// without `as` the module is bound to the file name minus its extension
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.currToken}
+
+	if !p.PeekAndMove(token.STRING) {
+		return nil
+	}
+
+	stmt.Path = &ast.String{Token: p.currToken, Value: p.currToken.Literal}
+
+	if p.aftToken.Type == token.AS {
+		p.NextToken()
+		if !p.PeekAndMove(token.IDENT) {
+			return nil
+		}
+		stmt.Alias = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	} else {
+		name := strings.TrimSuffix(path.Base(stmt.Path.Value), path.Ext(stmt.Path.Value))
+		if !isIdentifier(name) {
+			msg := fmt.Sprintf("cannot use %q as a module name, add `as <name>`", name)
+			p.addError(stmt.Path.Pos(), msg)
+			return nil
+		}
+		stmt.Alias = &ast.Identifier{Token: stmt.Path.Token, Value: name}
+	}
+
+	if p.aftToken.Type == token.SEMICOLON {
+		p.NextToken()
+	}
+
+	return stmt
+}
+
+// reports whether name would lex as a single identifier
+func isIdentifier(name string) bool {
+	tkn := lexer.New(name).NextToken()
+	return tkn.Type == token.IDENT && tkn.Literal == name
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.currToken}
+
+	if p.labelFollows() {
+		p.NextToken()
+		stmt.Label = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	}
+
+	if p.aftToken.Type == token.SEMICOLON {
+		p.NextToken()
+	}
+
+	return stmt
+}
+
+// semicolons are optional, so a name on the next line starts a new
+// statement instead of being the label of break or continue
+func (p *Parser) labelFollows() bool {
+	return p.aftToken.Type == token.IDENT && p.aftToken.Pos.Line == p.currToken.Pos.Line
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.currToken}
+
+	if p.labelFollows() {
+		p.NextToken()
+		stmt.Label = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	}
+
+	if p.aftToken.Type == token.SEMICOLON {
+		p.NextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.currToken}
 
@@ -445,6 +1011,20 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.currToken}
+
+	p.NextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.aftToken.Type == token.SEMICOLON {
+		p.NextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.currToken}
 
@@ -497,7 +1077,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
-	for p.currToken.Type != token.SEMICOLON {
+	for p.currToken.Type != token.SEMICOLON && p.currToken.Type != token.EOF {
 		p.NextToken()
 	}
 
@@ -509,7 +1089,21 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as an int64", p.currToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.currToken.Pos, msg)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.currToken}
+	value, err := strconv.ParseFloat(p.currToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as a float64", p.currToken.Literal)
+		p.addError(p.currToken.Pos, msg)
 		return nil
 	}
 
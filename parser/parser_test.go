@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"go_interpreter/ast"
 	"go_interpreter/lexer"
+	"strings"
 	"testing"
 )
 
@@ -109,3 +110,623 @@ func testInfixExpression(t *testing.T, exp ast.Expression, left interface{},
 	}
 	return true
 }
+
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	expected := map[string]int64{
+		"one":   1,
+		"two":   2,
+		"three": 3,
+	}
+
+	for key, value := range hash.Pairs {
+		literal, ok := key.(*ast.String)
+		if !ok {
+			t.Errorf("key is not ast.String. got=%T", key)
+		}
+		testIntegerLiteral(t, value, expected[literal.Value])
+	}
+
+	order := []string{}
+	for _, key := range hash.Keys {
+		order = append(order, key.String())
+	}
+	if strings.Join(order, " ") != "one two three" {
+		t.Errorf("hash.Keys not in source order. got=%v", order)
+	}
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	input := "{}"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+func TestHashLiteralDoesNotClashWithBlocks(t *testing.T) {
+	input := `if (true) { 1 } else { {"a": 1} }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("exp is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+
+	alt := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	if _, ok := alt.Expression.(*ast.HashLiteral); !ok {
+		t.Fatalf("alternative is not ast.HashLiteral. got=%T", alt.Expression)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		operator string
+		expected string
+	}{
+		{"x = 5;", "x", "=", "x = 5"},
+		{"x += 1;", "x", "+=", "x += 1"},
+		{"x -= 1;", "x", "-=", "x -= 1"},
+		{"x *= 2;", "x", "*=", "x *= 2"},
+		{"x = y = 1 + 2;", "x", "=", "x = y = (1 + 2)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("exp is not ast.AssignExpression. got=%T", stmt.Expression)
+		}
+		if !testIdentifier(t, exp.Target, tt.name) {
+			return
+		}
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator is not %q. got=%q", tt.operator, exp.Operator)
+		}
+		if exp.String() != tt.expected {
+			t.Errorf("exp.String() is not %q. got=%q", tt.expected, exp.String())
+		}
+	}
+}
+
+func TestIndexAssignExpression(t *testing.T) {
+	l := lexer.New("arr[i + 1] += 2;")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("exp is not ast.AssignExpression. got=%T", stmt.Expression)
+	}
+	if _, ok := exp.Target.(*ast.IndexExpression); !ok {
+		t.Fatalf("exp.Target is not ast.IndexExpression. got=%T", exp.Target)
+	}
+	if exp.String() != "(arr[(i + 1)]) += 2" {
+		t.Errorf("exp.String() wrong. got=%q", exp.String())
+	}
+}
+
+func TestAssignToNonIdentifier(t *testing.T) {
+	l := lexer.New("1 = 2;")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser errors for assignment to a literal")
+	}
+}
+
+func TestLabeledLoops(t *testing.T) {
+	input := `outer: while (true) { break outer; continue; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	loop, ok := stmt.Expression.(*ast.WhileExpression)
+	if !ok {
+		t.Fatalf("exp is not ast.WhileExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, loop.Label, "outer") {
+		return
+	}
+
+	brk, ok := loop.Consequence.Statements[0].(*ast.BreakStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.BreakStatement. got=%T", loop.Consequence.Statements[0])
+	}
+	testIdentifier(t, brk.Label, "outer")
+
+	cont, ok := loop.Consequence.Statements[1].(*ast.ContinueStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.ContinueStatement. got=%T", loop.Consequence.Statements[1])
+	}
+	if cont.Label != nil {
+		t.Errorf("cont.Label is not nil. got=%s", cont.Label)
+	}
+}
+
+func TestLabelOnlyOnSameLine(t *testing.T) {
+	input := "while (true) {\n break\n n = 100\n continue\n n\n}"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	loop := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.WhileExpression)
+	if len(loop.Consequence.Statements) != 4 {
+		t.Fatalf("wrong number of statements. got=%d", len(loop.Consequence.Statements))
+	}
+
+	brk, ok := loop.Consequence.Statements[0].(*ast.BreakStatement)
+	if !ok || brk.Label != nil {
+		t.Errorf("break took the next line as its label. got=%s", loop.Consequence.Statements[0])
+	}
+
+	cont, ok := loop.Consequence.Statements[2].(*ast.ContinueStatement)
+	if !ok || cont.Label != nil {
+		t.Errorf("continue took the next line as its label. got=%s", loop.Consequence.Statements[2])
+	}
+}
+
+func TestLogicalOperatorPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a < b && c < d", "((a < b) && (c < d))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c", "((a && b) || c)"},
+		{"a == b || c != d", "((a == b) || (c != d))"},
+		{"x = a || b", "x = (a || b)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	input := "var a = 1;\nvar b = (2;"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.ParseErrors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+
+	if errors[0].Pos.Line != 2 || errors[0].Pos.Column != 11 {
+		t.Errorf("wrong error position. got=%s", errors[0].Pos)
+	}
+	if p.Errors()[0] != "2:11: expected ) as aftToken, got ; instead" {
+		t.Errorf("wrong error message. got=%q", p.Errors()[0])
+	}
+}
+
+func TestUnterminatedStringIsParseError(t *testing.T) {
+	l := lexer.New(`var s = "oops;`)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+	if errors[0] != "1:9: unterminated string literal" {
+		t.Errorf("wrong error. got=%q", errors[0])
+	}
+}
+
+func TestComparisonOperatorParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		left     interface{}
+		operator string
+		right    interface{}
+	}{
+		{"a <= b;", "a", "<=", "b"},
+		{"5 >= 3;", 5, ">=", 3},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		testInfixExpression(t, stmt.Expression, tt.left, tt.operator, tt.right)
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	tests := []struct {
+		input string
+		path  string
+		alias string
+	}{
+		{`import "lib/strings.catt" as s;`, "lib/strings.catt", "s"},
+		{`import "lib/strings.catt";`, "lib/strings.catt", "strings"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("stmt is not ast.ImportStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Path.Value != tt.path {
+			t.Errorf("stmt.Path wrong. expected=%q, got=%q", tt.path, stmt.Path.Value)
+		}
+		if stmt.Alias.Value != tt.alias {
+			t.Errorf("stmt.Alias wrong. expected=%q, got=%q", tt.alias, stmt.Alias.Value)
+		}
+	}
+}
+
+func TestMemberExpression(t *testing.T) {
+	l := lexer.New("s.upper(x)[0]")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != "(s.upper(x)[0])" {
+		t.Errorf("wrong program. got=%q", program.String())
+	}
+}
+
+func TestTailCallMarking(t *testing.T) {
+	input := `fn(n) {
+		a(1);
+		while (n) { return b(2); };
+		if (n) { c(3) } else if (n) { d(4) } else { n * e(5) }
+	}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	fnc := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+
+	tails := map[string]bool{}
+	var collect func(node ast.Node)
+	collect = func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.BlockStatement:
+			for _, stmt := range node.Statements {
+				collect(stmt)
+			}
+		case *ast.ExpressionStatement:
+			collect(node.Expression)
+		case *ast.ReturnStatement:
+			collect(node.Value)
+		case *ast.WhileExpression:
+			collect(node.Consequence)
+		case *ast.IfExpression:
+			collect(node.Consequence)
+			if node.CondAlternative != nil {
+				collect(node.CondAlternative)
+			}
+			if node.Alternative != nil {
+				collect(node.Alternative)
+			}
+		case *ast.InfixExpression:
+			collect(node.Right)
+		case *ast.CallExpression:
+			tails[node.Function.String()] = node.Tail
+		}
+	}
+	collect(fnc.Body)
+
+	expected := map[string]bool{"a": false, "b": true, "c": true, "d": true, "e": false}
+	for name, tail := range expected {
+		if tails[name] != tail {
+			t.Errorf("call %s: Tail is %t, want %t", name, tails[name], tail)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"arr[1:3]", "(arr[1:3])"},
+		{"arr[:2]", "(arr[:2])"},
+		{"arr[1:]", "(arr[1:])"},
+		{"arr[:]", "(arr[:])"},
+		{"arr[-2:len(arr) - 1]", "(arr[(-2):(len(arr) - 1)])"},
+		{"arr[-1]", "(arr[(-1)])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestConstStatement(t *testing.T) {
+	l := lexer.New("const limit = 10;")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.LetStatement. got=%T", program.Statements[0])
+	}
+	if !stmt.Constant() || stmt.Name.Value != "limit" || stmt.String() != "const limit = 10;" {
+		t.Errorf("wrong const statement. got=%q", stmt.String())
+	}
+}
+
+func TestStructStatement(t *testing.T) {
+	l := lexer.New("struct Cat { name, age }; c.age += 1;")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.StructStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Name.Value != "Cat" || len(stmt.Fields) != 2 ||
+		stmt.Fields[0].Value != "name" || stmt.Fields[1].Value != "age" {
+		t.Errorf("wrong struct. got=%s", stmt)
+	}
+
+	if program.Statements[1].String() != "c.age += 1" {
+		t.Errorf("wrong member assignment. got=%q", program.Statements[1].String())
+	}
+}
+
+func TestStructStatementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Cat { name, name }", "1:20: duplicate field name in struct Cat"},
+		{"struct { name }", "1:8: expected IDENT as aftToken, got { instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { f() } catch (e) { e.message }`, "try f() catch(e) e.message"},
+		{`try { f() } finally { g() }`, "try f() finally g()"},
+		{`try { f() } catch (e) { 1 } finally { g() }`, "try f() catch(e) 1 finally g()"},
+		{`throw "boom";`, `throw boom;`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestTryNeedsCatchOrFinally(t *testing.T) {
+	l := lexer.New("try { f() } g()")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	expected := "1:13: expected catch or finally after try, got IDENT instead"
+	if len(errors) == 0 || errors[0] != expected {
+		t.Errorf("wrong errors. want=%q, got=%v", expected, errors)
+	}
+}
+
+func TestCallsInTryAreNotTailCalls(t *testing.T) {
+	l := lexer.New("fn() { try { return f() } catch (e) { g() } }")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	fnc := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	try := fnc.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.TryExpression)
+
+	ret := try.Block.Statements[0].(*ast.ReturnStatement)
+	if ret.Value.(*ast.CallExpression).Tail {
+		t.Errorf("call in try block marked as tail call")
+	}
+
+	call := try.Catch.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if call.Tail {
+		t.Errorf("call in catch block marked as tail call")
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match x { 1 => "one", -2.5 => { y }, [a, [_, "b"]] => a, n => n + 1 }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	match, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("expression is not ast.MatchExpression. got=%T", stmt.Expression)
+	}
+
+	if len(match.Arms) != 4 {
+		t.Fatalf("wrong number of arms. got=%d", len(match.Arms))
+	}
+
+	expected := `match x { 1 => one, (-2.5) => y, [a,[_,b]] => a, n => (n + 1) }`
+	if match.String() != expected {
+		t.Errorf("wrong match. want=%q, got=%q", expected, match.String())
+	}
+}
+
+func TestInvalidMatchPattern(t *testing.T) {
+	l := lexer.New(`match x { a + 1 => 1 }`)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	expected := "1:13: invalid pattern: (a + 1)"
+	if len(errors) == 0 || errors[0] != expected {
+		t.Errorf("wrong errors. want=%q, got=%v", expected, errors)
+	}
+}
+
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn() {}", "fn()"},
+		{"fn(x, y) {}", "fn(x, y)"},
+		{"fn(x, y = x * 2) {}", "fn(x, y = (x * 2))"},
+		{"fn(...rest) {}", "fn(...rest)"},
+		{"fn(x, y = 1, ...rest) {}", "fn(x, y = 1, ...rest)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong function for %q. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x = 1, y) {}", "1:11: missing default value for parameter y"},
+		{"fn(...rest, x) {}", "1:11: rest parameter must be the last parameter"},
+		{"fn(1) {}", "1:4: expected IDENT as aftToken, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestFunctionStatement(t *testing.T) {
+	l := lexer.New("fn add(x, y = 1) { x + y } fn(x) { x }(1);")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.FunctionStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Name.Value != "add" || stmt.String() != "fn add(x, y = 1)(x + y)" {
+		t.Errorf("wrong function statement. got=%q", stmt.String())
+	}
+
+	if _, ok := program.Statements[1].(*ast.ExpressionStatement); !ok {
+		t.Errorf("anonymous function is not an expression statement. got=%T", program.Statements[1])
+	}
+}
@@ -0,0 +1,92 @@
+package repl
+
+import (
+	"fmt"
+	"go_interpreter/object"
+	"go_interpreter/parser"
+	"go_interpreter/token"
+	"io"
+	"os"
+	"strings"
+)
+
+// prints every parser error followed by the source line it points at
+func PrintParserErrors(out io.Writer, source string, errors []*parser.ParseError) {
+	for _, err := range errors {
+		io.WriteString(out, "\t"+err.Error()+"\n")
+		io.WriteString(out, Excerpt(source, err.Pos))
+	}
+}
+
+func PrintRuntimeError(out io.Writer, source string, err *object.Error) {
+	msg := err.Message
+	if err.Pos.IsValid() {
+		msg = err.Pos.String() + ": " + msg
+	}
+
+	io.WriteString(out, "ERROR: "+msg+"\n")
+	io.WriteString(out, Excerpt(sourceFor(err.Pos, source), err.Pos))
+	PrintTraceback(out, err.Stack)
+}
+
+// prints the function calls an error unwound through, most recent first
+func PrintTraceback(out io.Writer, stack []object.Frame) {
+	if len(stack) == 0 {
+		return
+	}
+
+	io.WriteString(out, "traceback (most recent call first):\n")
+	for _, frame := range stack {
+		io.WriteString(out, "\tat "+frame.String()+"\n")
+	}
+}
+
+// errors raised inside an imported module point into that module's file
+// rather than the source that was run
+func sourceFor(pos token.Position, fallback string) string {
+	if pos.File == "" {
+		return fallback
+	}
+
+	source, err := os.ReadFile(pos.File)
+	if err != nil {
+		return fallback
+	}
+
+	return string(source)
+}
+
+// renders the source line at pos with a caret under the column, empty when
+// pos is unknown or outside of source
+func Excerpt(source string, pos token.Position) string {
+	if !pos.IsValid() {
+		return ""
+	}
+
+	lines := strings.Split(source, "\n")
+	if pos.Line > len(lines) {
+		return ""
+	}
+
+	line := strings.TrimRight(lines[pos.Line-1], "\r")
+	gutter := fmt.Sprintf("%d", pos.Line)
+
+	// keep tabs so the caret lines up with what the terminal shows
+	var padding strings.Builder
+	for _, ch := range []rune(line) {
+		if padding.Len() >= pos.Column-1 {
+			break
+		}
+		if ch == '\t' {
+			padding.WriteRune('\t')
+		} else {
+			padding.WriteRune(' ')
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("\t%s | %s\n", gutter, line))
+	out.WriteString(fmt.Sprintf("\t%s | %s^\n", strings.Repeat(" ", len(gutter)), padding.String()))
+
+	return out.String()
+}
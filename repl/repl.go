@@ -27,22 +27,17 @@ func Start(in io.Reader, out io.Writer) {
 		p := parser.New(l)
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			PrintParserErrors(out, line, p.ParseErrors())
 			continue
 		}
 
 		evaluated := evaluator.Eval(program, env)
 		if evaluated != nil {
-			if evaluated.Type() == object.ERROR_OBJ {
-				io.WriteString(out, evaluated.Inspect())
+			if err, ok := evaluated.(*object.Error); ok {
+				PrintRuntimeError(out, line, err)
+				continue
 			}
 			io.WriteString(out, "\n")
 		}
 	}
 }
-
-func printParserErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
-	}
-}
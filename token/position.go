@@ -0,0 +1,27 @@
+package token
+
+import "fmt"
+
+// where a token starts in the source, Line and Column are 1 based
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// a zero Position means the location is unknown
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return p.File
+	}
+
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
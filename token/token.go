@@ -5,17 +5,34 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+	// comments found between the previous token and this one, the
+	// evaluator ignores them but a formatter can put them back
+	Comments []Comment
+}
+
+// Text holds the whole comment including the // or /* */ markers
+type Comment struct {
+	Text string
+	Pos  Position
 }
 
 const (
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
 	IF    = "IF"
 	ELSE  = "ELSE"
 	IDENT = "IDENT"
 
-	ASSIGN = "="
+	ASSIGN       = "="
+	ARROW        = "=>"
+	PLUS_ASSIGN  = "+="
+	MINUS_ASSIGN = "-="
+	ASTR_ASSIGN  = "*="
+	SLASH_ASSIGN = "/="
+
 	PLUS   = "+"
 	ASTR   = "*"
 	SLASH  = "/"
@@ -30,8 +47,13 @@ const (
 	NOT_EQ = "!="
 	LT     = "<"
 	GT     = ">"
+	LT_EQ  = "<="
+	GT_EQ  = ">="
 
 	COMMA     = ","
+	DOT       = "."
+	ELLIPSIS  = "..."
+	COLON     = ":"
 	SEMICOLON = ";"
 	EOF       = "EOF"
 
@@ -43,6 +65,7 @@ const (
 	RBRACKET = "]"
 
 	VAR         = "VAR"
+	CONST       = "CONST"
 	FUNCTION    = "FUNCTION"
 	NOT_ALLOWED = "NOT_ALLOWED"
 	RETURN      = "RETURN"
@@ -50,21 +73,46 @@ const (
 	BANG  = "!"
 	MINUS = "-"
 
-	WHILE = "WHILE"
-	FOR   = "FOR"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+
+	IMPORT = "IMPORT"
+	AS     = "AS"
+
+	STRUCT = "STRUCT"
+
+	TRY     = "TRY"
+	CATCH   = "CATCH"
+	FINALLY = "FINALLY"
+	THROW   = "THROW"
+
+	MATCH = "MATCH"
 )
 
 // keywords dict for indetifiers
 var keywords = map[string]TokenType{
-	"var":    VAR,
-	"false":  FALSE,
-	"true":   TRUE,
-	"if":     IF,
-	"else":   ELSE,
-	"while":  WHILE,
-	"for":    FOR,
-	"fn":     FUNCTION,
-	"return": RETURN,
+	"var":      VAR,
+	"const":    CONST,
+	"false":    FALSE,
+	"true":     TRUE,
+	"if":       IF,
+	"else":     ELSE,
+	"while":    WHILE,
+	"for":      FOR,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"import":   IMPORT,
+	"as":       AS,
+	"struct":   STRUCT,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+	"match":    MATCH,
+	"fn":       FUNCTION,
+	"return":   RETURN,
 }
 
 // if our input is in our keywords map return the token othewise
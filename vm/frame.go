@@ -0,0 +1,50 @@
+package vm
+
+import (
+	"go_interpreter/code"
+	"go_interpreter/object"
+	"go_interpreter/token"
+)
+
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+
+	// where the call that made this frame was, for tracebacks
+	callPos token.Position
+	// the frame name the evaluator gave a call made by a builtin
+	callback string
+
+	// calls this frame replaced through tail calls, oldest first, so
+	// tracebacks still show them
+	tailFrames []object.Frame
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+// a local variable slot, closures capture the cell itself so they see
+// later assignments and can make their own
+type cell struct {
+	value    object.Object
+	constant bool
+	// set for the vars of a block, which share their slot with other blocks
+	name string
+}
+
+func (c *cell) Type() object.ObjectType {
+	return "CELL"
+}
+
+func (c *cell) Inspect() string {
+	if c.value == nil {
+		return "<empty cell>"
+	}
+	return c.value.Inspect()
+}
@@ -0,0 +1,770 @@
+package vm
+
+import (
+	"fmt"
+	"go_interpreter/code"
+	"go_interpreter/compiler"
+	"go_interpreter/evaluator"
+	"go_interpreter/object"
+)
+
+const StackSize = 1 << 16
+
+// how deeply calls can nest before a program fails with a stack overflow,
+// a call in tail position reuses the frame of its caller and does not count
+const MaxFrames = 1 << 14
+
+// how many frames replaced by tail calls a traceback can show
+const maxTailFrames = 64
+
+// how many frames a traceback keeps, the ones nearest to the error
+const maxTraceback = 64
+
+// operator of every binary opcode, the evaluator does the actual work
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterThan:  ">",
+	code.OpLessThan:     "<",
+	code.OpGreaterEqual: ">=",
+	code.OpLessEqual:    "<=",
+}
+
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	sp    int // always points to the next free slot, top of stack is stack[sp-1]
+
+	globals     []object.Object
+	globalNames []string
+	// whether the global in the same slot was declared with const
+	constGlobals []bool
+
+	frames      []*Frame
+	framesIndex int
+
+	// installed by OpTry, innermost last
+	handlers []handler
+
+	lastPopped object.Object
+
+	// what every closure made here runs when a builtin calls it
+	callback func(cl *object.Closure, frame object.Frame, args ...object.Object) object.Object
+}
+
+// where an error raised inside a try goes, frameIndex and sp are what they
+// were when the try started
+type handler struct {
+	frameIndex int
+	sp         int
+	catchIP    int
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		NumLocals:    len(bytecode.MainLocals),
+		Name:         "<main>",
+		SourceMap:    bytecode.SourceMap,
+		LocalNames:   bytecode.MainLocals,
+	}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	vm := &VM{
+		constants: bytecode.Constants,
+
+		// the main frame's locals sit at the bottom of the stack
+		stack: make([]object.Object, StackSize),
+		sp:    len(bytecode.MainLocals),
+
+		globals:      make([]object.Object, bytecode.NumGlobals),
+		globalNames:  bytecode.GlobalNames,
+		constGlobals: make([]bool, bytecode.NumGlobals),
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+	vm.callback = vm.callBack
+
+	return vm
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) *object.Error {
+	if vm.framesIndex >= MaxFrames {
+		return newError("stack overflow")
+	}
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// runs the program and returns what the evaluator would: the value of the
+// last expression statement, the value of a top level return, or the
+// *object.Error that stopped it
+func (vm *VM) Run() object.Object {
+	return vm.run(1)
+}
+
+// runs until the frame at depth base returns, base 1 is the main program.
+// Builtins calling back into the vm start a nested run for their callback
+func (vm *VM) run(base int) object.Object {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		var err *object.Error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(vm.constants[constIndex])
+
+		case code.OpPop:
+			vm.lastPopped = vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterEqual, code.OpLessEqual:
+			right := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.InfixOperation(infixOperators[op], left, right))
+
+		case code.OpTrue:
+			err = vm.push(evaluator.TRUE)
+
+		case code.OpFalse:
+			err = vm.push(evaluator.FALSE)
+
+		case code.OpNull:
+			err = vm.push(evaluator.NULL)
+
+		case code.OpMinus:
+			err = vm.pushResult(evaluator.PrefixOperation("-", vm.pop()))
+
+		case code.OpBang:
+			err = vm.pushResult(evaluator.PrefixOperation("!", vm.pop()))
+
+		case code.OpTruthy:
+			err = vm.push(evaluator.NativeBool(evaluator.IsTruthy(vm.pop())))
+
+		case code.OpCheckBool:
+			condition := vm.stack[vm.sp-1]
+			if condition.Type() != object.BOOL_OBJ {
+				err = newError("expected type BOOL got: %s", condition.Type())
+			}
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !evaluator.IsTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			value := vm.globals[globalIndex]
+			if value == nil {
+				err = usedBeforeDeclaration(slotName(vm.globalNames, int(globalIndex)))
+				break
+			}
+			err = vm.push(value)
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if vm.constGlobals[globalIndex] {
+				err = constantError("cannot assign to constant", slotName(vm.globalNames, int(globalIndex)))
+				break
+			}
+			if vm.globals[globalIndex] == nil {
+				err = usedBeforeDeclaration(slotName(vm.globalNames, int(globalIndex)))
+				break
+			}
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpDefineGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			constant := code.ReadUint8(ins[ip+3:]) == 1
+			vm.currentFrame().ip += 3
+
+			if vm.constGlobals[globalIndex] {
+				err = constantError("cannot redeclare constant", slotName(vm.globalNames, int(globalIndex)))
+				break
+			}
+			vm.globals[globalIndex] = vm.pop()
+			vm.constGlobals[globalIndex] = constant
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			c, ok := vm.stack[frame.basePointer+int(localIndex)].(*cell)
+			if !ok || c.value == nil {
+				err = usedBeforeDeclaration(vm.localName(int(localIndex)))
+				break
+			}
+			err = vm.push(c.value)
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			c := vm.localCell(int(localIndex))
+			if c.constant {
+				err = constantError("cannot assign to constant", vm.localName(int(localIndex)))
+				break
+			}
+			if c.value == nil {
+				err = usedBeforeDeclaration(vm.localName(int(localIndex)))
+				break
+			}
+			c.value = vm.pop()
+
+		case code.OpDefineLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			constant := code.ReadUint8(ins[ip+2:]) == 1
+			vm.currentFrame().ip += 2
+
+			c := vm.localCell(int(localIndex))
+			if c.constant {
+				err = constantError("cannot redeclare constant", vm.localName(int(localIndex)))
+				break
+			}
+			c.value = vm.pop()
+			c.constant = constant
+
+		case code.OpGetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.push(vm.localCell(int(localIndex)))
+
+		case code.OpFreshLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			name := vm.constants[code.ReadUint16(ins[ip+2:])].(*object.String).Value
+			vm.currentFrame().ip += 3
+
+			vm.stack[vm.currentFrame().basePointer+int(localIndex)] = &cell{name: name}
+
+		case code.OpCopyLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			old := vm.localCell(int(localIndex))
+			vm.stack[vm.currentFrame().basePointer+int(localIndex)] = &cell{value: old.value, constant: old.constant, name: old.name}
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			fn := vm.currentFrame().cl
+			c := fn.Free[freeIndex].(*cell)
+			if c.value == nil {
+				err = usedBeforeDeclaration(slotName(fn.Fn.FreeNames, int(freeIndex)))
+				break
+			}
+			err = vm.push(c.value)
+
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			fn := vm.currentFrame().cl
+			c := fn.Free[freeIndex].(*cell)
+			if c.constant {
+				err = constantError("cannot assign to constant", slotName(fn.Fn.FreeNames, int(freeIndex)))
+				break
+			}
+			if c.value == nil {
+				err = usedBeforeDeclaration(slotName(fn.Fn.FreeNames, int(freeIndex)))
+				break
+			}
+			c.value = vm.pop()
+
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.push(vm.currentFrame().cl.Free[freeIndex])
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(&object.Array{Elements: elements})
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash := evaluator.HashOperation(vm.stack[vm.sp-numElements : vm.sp])
+			vm.sp = vm.sp - numElements
+
+			err = vm.pushResult(hash)
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.IndexOperation(left, index))
+
+		case code.OpSlice:
+			high := vm.pop()
+			low := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.SliceOperation(left, low, high))
+
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.SetIndexOperation(left, index, value))
+
+		case code.OpDup2:
+			first, second := vm.stack[vm.sp-2], vm.stack[vm.sp-1]
+			if err = vm.push(first); err == nil {
+				err = vm.push(second)
+			}
+
+		case code.OpDup:
+			err = vm.push(vm.stack[vm.sp-1])
+
+		case code.OpGetMember:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			name := vm.constants[constIndex].(*object.String).Value
+			err = vm.pushResult(evaluator.MemberOperation(vm.pop(), name))
+
+		case code.OpSetMember:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			name := vm.constants[constIndex].(*object.String).Value
+			value := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.SetMemberOperation(left, name, value))
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.executeCall(int(numArgs), false)
+
+		case code.OpTailCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.executeCall(int(numArgs), vm.framesIndex > 1)
+
+		case code.OpReturnValue, code.OpReturn:
+			var returnValue object.Object = evaluator.NULL
+			if op == code.OpReturnValue {
+				returnValue = vm.pop()
+			}
+
+			if vm.framesIndex == 1 {
+				return returnValue
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			if vm.framesIndex < base {
+				return returnValue
+			}
+			err = vm.push(returnValue)
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			err = vm.pushClosure(int(constIndex), int(numFree))
+
+		case code.OpError:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = newError("%s", vm.constants[constIndex].(*object.String).Value)
+
+		case code.OpThrow:
+			// finally blocks rethrow the error they were entered with as is
+			thrown := vm.pop()
+			if thrownErr, ok := thrown.(*object.Error); ok {
+				err = thrownErr
+			} else {
+				err = evaluator.ThrowOperation(thrown)
+			}
+
+		case code.OpTry:
+			catchIP := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			vm.handlers = append(vm.handlers, handler{frameIndex: vm.framesIndex, sp: vm.sp, catchIP: catchIP})
+
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpCatch:
+			err = vm.push(evaluator.ErrorValue(vm.pop().(*object.Error)))
+
+		case code.OpMatchValue:
+			pattern := vm.pop()
+			value := vm.pop()
+			err = vm.push(evaluator.NativeBool(evaluator.MatchOperation(pattern, value)))
+
+		case code.OpMatchArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array, ok := vm.pop().(*object.Array)
+			err = vm.push(evaluator.NativeBool(ok && len(array.Elements) == numElements))
+		}
+
+		if err != nil {
+			if vm.catch(err, base) {
+				continue
+			}
+			return vm.fail(err, base)
+		}
+	}
+
+	return vm.lastPopped
+}
+
+// the cell in local slot index of the current frame, created on first use
+func (vm *VM) localCell(index int) *cell {
+	slot := vm.currentFrame().basePointer + index
+
+	c, ok := vm.stack[slot].(*cell)
+	if !ok {
+		c = &cell{}
+		vm.stack[slot] = c
+	}
+	return c
+}
+
+// tail calls reuse the frame of the caller instead of pushing a new one
+func (vm *VM) executeCall(numArgs int, tail bool) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs, tail)
+	case *object.BuiltIn:
+		return vm.callBuiltin(callee, numArgs)
+	case *object.StructType:
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+		vm.sp = vm.sp - numArgs - 1
+		return vm.pushResult(evaluator.NewRecord(callee, args))
+	default:
+		return newError("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int, tail bool) *object.Error {
+	fn := cl.Fn
+	required := fn.NumParameters - fn.NumDefaults
+	if err := evaluator.CheckArity(required, fn.NumParameters, fn.Variadic, numArgs); err != nil {
+		return err
+	}
+
+	// the arguments past the parameters go to the rest parameter
+	var rest *object.Array
+	if fn.Variadic {
+		rest = &object.Array{Elements: []object.Object{}}
+		if numArgs > fn.NumParameters {
+			extra := numArgs - fn.NumParameters
+			rest.Elements = make([]object.Object, extra)
+			copy(rest.Elements, vm.stack[vm.sp-extra:vm.sp])
+			vm.sp -= extra
+			numArgs = fn.NumParameters
+		}
+	}
+
+	caller := vm.currentFrame()
+	callPos := caller.cl.Fn.SourceMap.Lookup(caller.ip)
+
+	basePointer := vm.sp - numArgs
+	var tailFrames []object.Frame
+	if tail {
+		// move the callee and its arguments over the current frame
+		current := vm.popFrame()
+		copy(vm.stack[current.basePointer-1:], vm.stack[vm.sp-1-numArgs:vm.sp])
+		basePointer = current.basePointer
+
+		tailFrames = append(tailFrames, current.tailFrames...)
+		if len(tailFrames) == maxTailFrames {
+			tailFrames = tailFrames[1:]
+		}
+		tailFrames = append(tailFrames, tracebackFrame(current))
+	}
+
+	if basePointer+cl.Fn.NumLocals >= StackSize {
+		return newError("stack overflow")
+	}
+
+	// every local lives in a cell, parameters get theirs now and the rest
+	// are created when first assigned
+	for i := 0; i < numArgs; i++ {
+		vm.stack[basePointer+i] = &cell{value: vm.stack[basePointer+i]}
+	}
+	for i := numArgs; i < cl.Fn.NumLocals; i++ {
+		vm.stack[basePointer+i] = nil
+	}
+	if rest != nil {
+		vm.stack[basePointer+fn.NumParameters] = &cell{value: rest}
+	}
+
+	frame := NewFrame(cl, basePointer)
+	if fn.EntryPoints != nil {
+		frame.ip = fn.EntryPoints[numArgs-required] - 1
+	}
+	frame.callPos = callPos
+	frame.tailFrames = tailFrames
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+	vm.sp = basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.BuiltIn, numArgs int) *object.Error {
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+
+	result := builtin.Fn(args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	return vm.pushResult(result)
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) *object.Error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return newError("not a function: %s", constant.Type())
+	}
+
+	free := make([]object.Object, numFree)
+	copy(free, vm.stack[vm.sp-numFree:vm.sp])
+	vm.sp = vm.sp - numFree
+
+	return vm.push(&object.Closure{Fn: function, Free: free, Run: vm.callback})
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if vm.sp >= StackSize {
+		return newError("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+// pushes the result of an operation, an *object.Error stops the program
+func (vm *VM) pushResult(o object.Object) *object.Error {
+	if err, ok := o.(*object.Error); ok {
+		return err
+	}
+	if o == nil {
+		o = evaluator.NULL
+	}
+	return vm.push(o)
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.lastPopped
+}
+
+// hands err to the innermost try started by this run, the frames above it
+// are dropped and the handler continues with err on the stack. Reports
+// false when there is no such try
+func (vm *VM) catch(err *object.Error, base int) bool {
+	if len(vm.handlers) == 0 {
+		return false
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	if h.frameIndex < base {
+		return false
+	}
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	vm.traceback(err, h.frameIndex)
+
+	vm.framesIndex = h.frameIndex
+	vm.sp = h.sp
+	vm.currentFrame().ip = h.catchIP - 1
+	vm.stack[vm.sp] = err
+	vm.sp++
+
+	return true
+}
+
+// turns err into an *object.Error positioned at the failing instruction
+// with a traceback built from the frames still on the stack
+//
+// only the frames of the run started at base are added, a nested run then
+// unwinds them so the builtin that started it can hand the error back
+func (vm *VM) fail(objErr *object.Error, base int) *object.Error {
+	vm.traceback(objErr, base-1)
+
+	if base > 1 {
+		vm.sp = vm.frames[base-1].basePointer - 1
+		vm.framesIndex = base - 1
+	}
+
+	return objErr
+}
+
+// positions objErr at the current instruction unless it already has a
+// position, and adds the frames from the current one down to stop
+func (vm *VM) traceback(objErr *object.Error, stop int) {
+	frame := vm.currentFrame()
+	if !objErr.Pos.IsValid() {
+		objErr.Pos = frame.cl.Fn.SourceMap.Lookup(frame.ip)
+	}
+
+	for i := vm.framesIndex - 1; i >= stop && i > 0; i-- {
+		frame := vm.frames[i]
+		addFrame(objErr, tracebackFrame(frame))
+
+		for j := len(frame.tailFrames) - 1; j >= 0; j-- {
+			addFrame(objErr, frame.tailFrames[j])
+		}
+	}
+}
+
+// deep recursion would otherwise print thousands of frames
+func addFrame(objErr *object.Error, frame object.Frame) {
+	if len(objErr.Stack) < maxTraceback {
+		objErr.Stack = append(objErr.Stack, frame)
+	}
+}
+
+func tracebackFrame(frame *Frame) object.Frame {
+	name := frame.callback
+	if name == "" {
+		name = frame.cl.Fn.Name
+	}
+	if name == "" {
+		name = "<anonymous fn>"
+	}
+
+	return object.Frame{Function: name, Pos: frame.callPos}
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func constantError(msg string, name string) *object.Error {
+	if name != "" {
+		return newError("%s %s", msg, name)
+	}
+	return newError(msg)
+}
+
+// every slot the compiler hands out belongs to a declaration, so one without
+// a value is used before its declaration ran
+func usedBeforeDeclaration(name string) *object.Error {
+	if name != "" {
+		return newError("identifier used before declaration: %s", name)
+	}
+	return newError("identifier used before declaration")
+}
+
+func slotName(names []string, index int) string {
+	if index < len(names) {
+		return names[index]
+	}
+	return ""
+}
+
+// block cells carry their own name since blocks take turns using a slot
+func (vm *VM) localName(index int) string {
+	frame := vm.currentFrame()
+	if c, ok := vm.stack[frame.basePointer+index].(*cell); ok && c.name != "" {
+		return c.name
+	}
+	return slotName(frame.cl.Fn.LocalNames, index)
+}
+
+// runs cl for a builtin that calls it back, like map, in a nested run. The
+// frame comes from the evaluator so the traceback names the builtin for an
+// anonymous closure, the same as the evaluator does
+func (vm *VM) callBack(cl *object.Closure, frame object.Frame, args ...object.Object) object.Object {
+	sp := vm.sp
+
+	if err := vm.push(cl); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := vm.push(arg); err != nil {
+			vm.sp = sp
+			return err
+		}
+	}
+
+	if err := vm.callClosure(cl, len(args), false); err != nil {
+		vm.sp = sp
+		return err
+	}
+	vm.currentFrame().callPos = frame.Pos
+	if !cl.Fn.Declared {
+		vm.currentFrame().callback = frame.Function
+	}
+
+	return vm.run(vm.framesIndex)
+}
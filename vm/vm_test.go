@@ -0,0 +1,359 @@
+package vm
+
+import (
+	"go_interpreter/compiler"
+	"go_interpreter/evaluator"
+	"go_interpreter/lexer"
+	"go_interpreter/object"
+	"go_interpreter/parser"
+	"strings"
+	"testing"
+)
+
+func testRun(t *testing.T, input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	return New(comp.Bytecode()).Run()
+}
+
+func testEval(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return evaluator.Eval(p.ParseProgram(), object.NewEnvironment())
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return evaluator.NULL.Inspect()
+	}
+	return obj.Inspect()
+}
+
+// every program has to give the same result on both backends
+func TestMatchesEvaluator(t *testing.T) {
+	tests := []string{
+		`1 + 2 * 3 - 4 / 2`,
+		`7 % 3 + 1.5`,
+		`"cat" + "nip"`,
+		`"a" < "b"`,
+		`!true == false`,
+		`-5 >= -5.0`,
+		`[1, 2] == [1, 2]`,
+		`[9007199254740993 == 9007199254740992, [9007199254740993] == [9007199254740992]]`,
+		`var h = {"a": 1, 2: [3]}; h[2][0] + h["a"]`,
+		`{"a": 1}["b"]`,
+		`var n = 0; var next = fn() { n += 1; n }; var h = {"b": next(), "a": next(), "c": next()}; [h["b"], h["a"], h["c"]]`,
+		`{"a": 1, "a": 2}["a"]`,
+		`var n = 0; {[1]: 1, "a": n = 5}; n`,
+		`if (1 > 2) { 10 } else { 20 }`,
+		`if (1 > 2) { 10 } else if (false) { 5 }`,
+		`if (false) { 10 }`,
+		`0 && missing`,
+		`1 || missing`,
+		`1 && 0`,
+		`var x = 1; x += 4; x *= 2; x`,
+		`var f = fn() {}; meowln(f());`,
+		`var f = fn() { var x = 1; }; [f(), len([f()])]`,
+		`var y = if (true) { var x = 1; }; [y]`,
+		`var x = 1; x += (x = 10)`,
+		`var a = [1]; a[0] += (a[0] = 10); a`,
+		`struct P { v } var p = P(1); p.v += (p.v = 10); p`,
+		`var h = {}; h["n"] += (h["n"] = 1)`,
+		`var add = fn(a, b) { a + b }; add(1, 2)`,
+		`var f = fn() { return 5; 10 }; f()`,
+		`var f = fn() { }; f()`,
+		`var fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(15)`,
+		`var adder = fn(a) { fn(b) { a + b } }; adder(2)(3)`,
+		`var counter = fn() { var c = 0; fn() { c += 1; c } }; var next = counter(); next(); next()`,
+		`var f = fn() { g() }; var g = fn() { 7 }; f()`,
+		`var s = 0; var i = 0; while (i < 10) { i += 1; if (i % 2 == 0) { continue; } s += i; } s`,
+		`var s = 0; for (var i = 0; i < 10; i += 1) { if (i == 4) { break; } s += i; } s`,
+		`var s = 0;
+		outer: for (var i = 0; i < 3; i += 1) {
+			for (var j = 0; j < 3; j += 1) {
+				if (j == 2) { continue outer; }
+				if (i == 2) { break outer; }
+				s += 1;
+			}
+		}
+		s`,
+		`var f = fn() { var i = 0; while (true) { i += 1; if (i == 3) { return i; } } }; f()`,
+		`var loop = fn(n, acc) { if (n == 0) { return acc; } loop(n - 1, acc + n) }; loop(100000, 0)`,
+		`cattsort([3, 1, 2])`,
+		`return 3; 4`,
+		`1 / 0`,
+		`missing`,
+		`y = 1`,
+		`5()`,
+		`var f = fn(a) { a }; f(1, 2)`,
+		`while (1) { 2 }`,
+		`break;`,
+		`"a" - "b"`,
+		`[1][5]`,
+		`{[1]: 2}`,
+		`var x = x + 1`,
+		`map([1, 2, 3], fn(x) { x * 2 })`,
+		`var k = 3; filter([1, 2, 3, 4], fn(x) { x >= k })`,
+		`reduce([1, 2, 3], 0, fn(acc, x) { acc + x })`,
+		`var total = 0; each([1, 2, 3], fn(x) { total += x; }); total`,
+		`map([[1], [2, 3]], len)`,
+		`var f = fn(x) { map(x, fn(y) { y / 0 }) }; f([1]); 5`,
+		`map([1, 2], fn(x) { map([x], fn(y) { y + x }) })`,
+		`[1, 2, 3, 4][1:3]`,
+		`[1, 2, 3, 4][-2:]`,
+		`"héllo"[:2] + "héllo"[-1]`,
+		`[1, 2]["a":]`,
+		`var a = [1, 2, 3]; a[0] = 9; a[-1] += 10; a`,
+		`var h = {"a": 1}; h["b"] = 2; h["a"] *= 5; h`,
+		`var grid = [[0, 0], [0, 0]]; grid[1][0] = 7; grid`,
+		`var a = [1]; a[3] = 1`,
+		`var s = "cat"; s[0] = "b"`,
+		`struct Cat { name, age } var c = Cat("tom", 3); c.age *= 2; c`,
+		`struct Cat { name, age } map([Cat("a", 1), Cat("b", 2)], fn(c) { c.name })`,
+		`struct Cat { name } Cat()`,
+		`struct Cat { name } Cat("tom").age`,
+		`var f = fn() { struct P { x } P(1) }; f().x`,
+		`try { throw "boom" } catch (e) { e }`,
+		`try { 1 } catch (e) { 2 }`,
+		`var f = fn(x) { if (x == 0) { throw "zero" } f(x - 1) }; try { f(2) } catch (e) { e.stack }`,
+		`var log = []; try { len(1) } catch (e) { log = push(log, e.message) } finally { log = push(log, "f") }; log`,
+		`var f = fn() { try { return 1 } finally { return 2 } }; f()`,
+		`var f = fn() { try { throw "a" } catch (e) { throw e.message + "b" } finally { 1 } }; try { f() } catch (e) { e }`,
+		`var s = 0; for (var i = 0; i < 5; i += 1) { try { if (i == 1) { continue } if (i == 3) { break } } finally { s += i } }; s`,
+		`var s = 0; outer: while (true) { while (true) { try { try { break outer } finally { s += 1 } } finally { s += 10 } } }; s`,
+		`try { throw "a" } finally { 1 }`,
+		`try { map([1, 0], fn(x) { 1 / x }) } catch (e) { e.message }`,
+		`match 2 { 1 => "one", 2 => "two", _ => "many" }`,
+		`match [1, [2, 3]] { [a] => a, [_, [x, y]] => x * y }`,
+		`match "ab" { [a, b] => 1, -1 => 2, s => s + "!" }`,
+		`match 5 { 1 => 1 }`,
+		`var x = 1; match 5 { x => x }; x`,
+		`var f = fn(v) { var x = 1; var r = match v { [x, y] => x + y }; r * x }; f([2, 3])`,
+		`var fs = map([1, 2], fn(v) { match v { n => fn() { n } } }); fs[0]() + fs[1]()`,
+		`match 5 { n => { var m = n * 2; m } }; m`,
+		`var f = fn(n) { match n { 0 => "done", _ => f(n - 1) } }; f(10000)`,
+		`var f = fn(x, y = x * 2, ...rest) { [x, y, rest] }; [f(1), f(1, 5), f(1, 5, 6, 7)]`,
+		`var f = fn(a, b) { a + b }; f(1)`,
+		`var f = fn(a, b) { a + b }; f(1, 2, 3)`,
+		`var f = fn(a, b = 1) { a }; f()`,
+		`var f = fn(a, ...b) { a }; f()`,
+		`map([1], fn(x, y) { x })`,
+		`var f = fn(a = missing) { a }; f()`,
+		`var loop = fn(n, acc = 0) { if (n == 0) { acc } else { loop(n - 1, acc + n) } }; loop(10000)`,
+		`var last = fn(...xs) { if (len(xs) == 1) { xs[0] } else { last(rest(xs)) } }; last(1, 2, 3)`,
+		`var r = double(4); fn double(x) { x * 2 } r`,
+		`fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } } fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } } isOdd(7)`,
+		`var f = fn() { var r = g(1); fn g(x) { x + h() } fn h() { 10 } r }; f()`,
+		`if (true) { 1; fn helper() { 2 } }`,
+		`const x = 5; x * 2`,
+		`const x = 5; x += 1; x`,
+		`const x = 5; var x = 6`,
+		`var x = 5; const x = 6; x`,
+		`const x = 5; var f = fn() { x = 1 }; f()`,
+		`var f = fn() { const y = 1; var g = fn() { y = 2 }; g() }; f()`,
+		`var f = fn() { const y = 1; const y = 2 }; f()`,
+		`const limit = 3; fn bump() { limit += 1 } try { bump() } catch (e) { e.message }`,
+		`const a = [1]; a[0] = 2; a`,
+		`const C = 1; struct C { a }; meowln(C(1))`,
+		`const k = 1; fn k() { 2 }; k`,
+		`var f = fn() { meowln(1); const k = 1; fn k() { 2 } }; f()`,
+		`const k = 1; if (true) { fn k() { 2 } k() }`,
+		`var f = fn() { const C = 1; struct C { a } }; f()`,
+		`if (true) { var x = 1; }; x`,
+		`var x = 1; if (true) { var x = 2; }; x`,
+		`var x = 1; if (true) { x = 2; }; x`,
+		`for (var i = 0; i < 3; i += 1) { }; i`,
+		`var i = 0; while (i < 3) { const step = 1; i += step; }; i`,
+		`try { throw "a" } catch (e) { 1 }; e`,
+		`var f = fn(x) { if (x) { var y = 1; } y }; f(true)`,
+		`var fs = []; for (var i = 0; i < 3; i += 1) { fs = push(fs, fn() { i }); }; map(fs, fn(f) { f() })`,
+		`var fs = []; for (var i = 0; i < 3; i += 1) { fs = push(fs, fn() { i += 10; i }); }; [fs[0](), fs[0](), fs[1]()]`,
+		`var fs = []; var i = 0; while (i < 3) { var v = i * 2; fs = push(fs, fn() { v }); i += 1; }; map(fs, fn(f) { f() })`,
+		`var fs = []; for (var i = 0; i < 4; i += 1) { if (i == 1) { continue; } fs = push(fs, fn() { i }); }; map(fs, fn(f) { f() })`,
+		`var g = fn() { var fs = []; for (var i = 0; i < 3; i += 1) { fs = push(fs, fn() { i }); } map(fs, fn(f) { f() }) }; g()`,
+		`var fs = []; for (var i = 0; i < 3; i += 1) { fs = push(fs, match i { n => fn() { n } }); }; map(fs, fn(f) { f() })`,
+		`var fs = []; for (var i = 0; i < 2; i += 1) { try { throw i } catch (e) { fs = push(fs, fn() { e }); } }; map(fs, fn(f) { f() })`,
+		`for (var i = 0; i < 2; i += 1) { fn twice(x) { x * 2 } twice(i) }`,
+		`var x = 1; if (true) { x = 2; var x = 3; }; meowln(x);`,
+		`var x = 1; if (true) { meowln(x); var x = 3; }`,
+		`var x = 1; if (true) { var y = x; var x = 3; y }`,
+		`var x = 1; if (true) { x += 1; var x = 3; }`,
+		`var x = 1; if (true) { var g = fn() { x }; var r = g(); var x = 3; r }`,
+		`var x = 1; if (true) { var g = fn() { x = 5 }; g(); var x = 3; }; x`,
+		`var x = 1; if (true) { var x = 3; x = 4; x }`,
+		`if (true) { var r = Cat(1); struct Cat { a } r }`,
+		`var x = 1; var f = fn() { x = 2; var x = 3; x }; f(); meowln(x);`,
+		`var f = fn() { meowln(x); var x = 3; }; var x = 1; f();`,
+		`var f = fn() { g() }; f(); var g = fn() { 1 };`,
+		`var f = fn() { var h = fn() { y }; var r = h(); var y = 1; r }; f()`,
+		`var f = fn() { var h = fn() { y = 5 }; h(); var y = 1; y }; f()`,
+		`var f = fn(a = b, b = 1) { a }; f()`,
+		`var f = fn(a = len(r), ...r) { a }; f()`,
+		`y = 1; var y = 2;`,
+		`var f = fn() { 1; fn g() { 2 } }; f()`,
+		`var f = fn(a) { a }; var x = len(1, 2);`,
+		`var f = fn(a) { a }; var x = f(1, 2);`,
+		`var g = fn(a) { a }; var h = fn() { g(1, 2) }; h()`,
+		`var f = fn() { len(1, 2) }; f()`,
+		`var x = 1; x(2)`,
+		`struct P { a } P(1, 2)`,
+		`try { throw [1, 2] } catch (e) { e.value[1] }`,
+		`try { try { throw {"code": 7} } catch (e) { throw e } } catch (e) { [e.value["code"], e.message] }`,
+		`try { len(1) } catch (e) { e }`,
+		`var a = [1, 1]; a[0] = a; var b = [1, 2]; b[0] = b; [a == a, a == b]`,
+		`var a = [1]; a[0] = a; meowln(a)`,
+		`var f = fn(n) { if (n == 0) { return 0; } 1 + f(n - 1) }; f(100000000)`,
+		`var f = fn(n) { if (n == 0) { return 0; } 1 + f(n - 1) }; try { f(100000000) } catch (e) { [e.message, f(10)] }`,
+		`var h = {"n": 1}; h["self"] = h; h`,
+		`struct Node { next } var n = Node(1); n.next = [n]; n`,
+		`break;`,
+		`var f = fn() { continue; }; f()`,
+		`var f = fn() { break; }; while (true) { f(); }`,
+	}
+
+	for _, input := range tests {
+		evaluated := testEval(input)
+		run := testRun(t, input)
+
+		expected, actual := inspect(evaluated), inspect(run)
+		if actual != expected {
+			t.Errorf("wrong result for %q. want=%s, got=%s", input, expected, actual)
+			continue
+		}
+
+		// errors also have to point at the same place
+		if err, ok := evaluated.(*object.Error); ok && run.(*object.Error).Pos != err.Pos {
+			t.Errorf("wrong error position for %q. want=%s, got=%s", input, err.Pos, run.(*object.Error).Pos)
+		}
+	}
+}
+
+// sibling blocks take turns using the same slots
+func TestManyBlocksMatchEvaluator(t *testing.T) {
+	input := "var r = if (true) { var a = 1;" +
+		strings.Repeat(" if (true) { var b = 255; }", 300) +
+		" var fs = []; for (var i = 0; i < 3; i += 1) { var k = i; fs = push(fs, fn() { k + a }); };" +
+		" [a, map(fs, fn(f) { f() })] }; r"
+
+	expected := inspect(testEval(input))
+	actual := inspect(testRun(t, input))
+
+	if actual != expected || expected != "[1, [1, 2, 3]]" {
+		t.Errorf("wrong result. want=%s, got=%s", expected, actual)
+	}
+}
+
+func TestErrorTraceback(t *testing.T) {
+	input := `var inner = fn(x) { x / 0 };
+var outer = fn() { var y = inner(1); y };
+outer();`
+
+	expected, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("evaluator did not return an error")
+	}
+
+	actual, ok := testRun(t, input).(*object.Error)
+	if !ok {
+		t.Fatalf("vm did not return an error")
+	}
+
+	if actual.Pos != expected.Pos {
+		t.Errorf("wrong position. want=%s, got=%s", expected.Pos, actual.Pos)
+	}
+
+	if len(actual.Stack) != len(expected.Stack) {
+		t.Fatalf("wrong stack length. want=%v, got=%v", expected.Stack, actual.Stack)
+	}
+
+	for i, frame := range expected.Stack {
+		if actual.Stack[i] != frame {
+			t.Errorf("wrong frame %d. want=%s, got=%s", i, frame, actual.Stack[i])
+		}
+	}
+}
+
+func TestFunctionStatementNameInTraceback(t *testing.T) {
+	input := `fn boom(x) { x / 0 }
+var alias = boom;
+alias(1);`
+
+	err, ok := testRun(t, input).(*object.Error)
+	if !ok {
+		t.Fatalf("vm did not return an error")
+	}
+
+	if len(err.Stack) != 1 || err.Stack[0].Function != "boom" {
+		t.Errorf("wrong stack. got=%v", err.Stack)
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	input := `var f = fn(n) { 1 + f(n + 1) }; f(0)`
+
+	err, ok := testRun(t, input).(*object.Error)
+	if !ok {
+		t.Fatalf("expected an error for unbounded recursion")
+	}
+
+	if err.Message != "stack overflow" {
+		t.Errorf("wrong error message. got=%q", err.Message)
+	}
+
+	if len(err.Stack) != maxTraceback {
+		t.Errorf("traceback was not truncated. got=%d frames", len(err.Stack))
+	}
+}
+
+func TestCallbackErrorUnwinds(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"var inner = fn(x) { x / 0 };\nvar outer = fn() { var r = map([1], inner); r };\nouter();", []string{"<map callback>", "outer"}},
+		{"fn named(x) { x / 0 }\nfilter([1], named)", []string{"named"}},
+		{"reduce([1, 2], 0, fn(acc, x) { acc / 0 })", []string{"<reduce callback>"}},
+	}
+
+	for _, tt := range tests {
+		expected, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Fatalf("evaluator did not return an error for %q", tt.input)
+		}
+		err, ok := testRun(t, tt.input).(*object.Error)
+		if !ok {
+			t.Fatalf("vm did not return an error for %q", tt.input)
+		}
+
+		if len(err.Stack) != len(tt.expected) || len(expected.Stack) != len(tt.expected) {
+			t.Fatalf("wrong stack for %q. want=%v, got=%v", tt.input, expected.Stack, err.Stack)
+		}
+		for i, name := range tt.expected {
+			if err.Stack[i] != expected.Stack[i] || err.Stack[i].Function != name {
+				t.Errorf("wrong frame %d for %q. want=%s, got=%s", i, tt.input, expected.Stack[i], err.Stack[i])
+			}
+		}
+	}
+}
+
+// a closure handed to a builtin is stored as it is, not as a wrapper
+func TestClosureKeepsIdentityThroughBuiltins(t *testing.T) {
+	input := `var a = fn() {}; [push([], a)[0] == a, first(map([1], fn(x) { a })) == a, filter([a], fn(f) { f == a }) == [a]]`
+
+	expected := inspect(testEval(input))
+	actual := inspect(testRun(t, input))
+
+	if actual != expected || actual != "[true, true, true]" {
+		t.Errorf("wrong result. want=%s, got=%s", expected, actual)
+	}
+}